@@ -0,0 +1,308 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/config"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/store"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const hnMaxConcurrency = 8
+
+// pollMaxItems is the number of items polled from every source, and of
+// the front pages recorded.
+const pollMaxItems = 100
+const discoveryInterval = 15 * time.Second
+
+// app serves the routes built from a configuration, its sources being
+// refreshed in the background until it is stopped.
+type app struct {
+	config config.Config
+	router http.Handler
+	// poller polls the sources, nil when they are fetched on request.
+	poller *services.Poller
+	stop   context.CancelFunc
+	// retiring is done once the app is retired, ending its streams and
+	// subscriptions so that their clients reconnect to the replacing app.
+	retiring   context.Context
+	endStreams context.CancelFunc
+
+	// active counts the requests being served, so that a retired app is
+	// only stopped once they are done. Retired apps serve no new requests.
+	mu      sync.Mutex
+	active  int
+	retired bool
+}
+
+// newApp registers the sources of appConfig, polled or cached, and builds
+// the built-in routes along with the routes composed from them. The sources
+// of previous, the app being replaced if any, keep serving their items
+// until polled again when their configuration is unchanged.
+func newApp(appConfig config.Config, itemStore store.Store, previous *app) (*app, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &app{config: appConfig}
+	a.retiring, a.endStreams = context.WithCancel(ctx)
+	registry := services.NewSourceRegistry()
+	breakers := make(map[string]*services.CircuitBreaker, len(appConfig.Sources))
+	clients := make([]*http.Client, 0, len(appConfig.Sources))
+	// The connections kept by the clients of the sources are closed once
+	// the app is stopped, every app having clients of its own.
+	a.stop = func() {
+		cancel()
+		for _, client := range clients {
+			client.CloseIdleConnections()
+		}
+	}
+	for _, source := range appConfig.Sources {
+		name := cmp.Or(source.Name, source.Key)
+		retriever, client := newConnector(source)
+		if client != nil {
+			clients = append(clients, client)
+		}
+		connector := services.NewInstrumentedRetriever(source.Key, retriever)
+		breakers[source.Key] = services.NewCircuitBreaker(name, store.NewRecorder(source.Key, connector, itemStore, pollMaxItems), services.DefaultBreakerConfig)
+		connectors := services.SourceConnectors{SourceName: name, Connector: breakers[source.Key], Timeout: source.Timeout, Weight: source.Weight, Site: source.Url}
+		if err := registry.Register(source.Key, connectors); err != nil {
+			a.stop()
+			return nil, fmt.Errorf("could not register source: %w", err)
+		}
+	}
+
+	var poller *services.Poller
+	if appConfig.PollInterval > 0 {
+		registry, poller = pollSources(registry, appConfig)
+		seedSnapshots(poller, appConfig, previous)
+		poller.Start(ctx)
+	} else if appConfig.CacheTTL > 0 {
+		registry = cacheSources(registry, appConfig.CacheTTL)
+	}
+
+	r := mux.NewRouter()
+	defaults := itemsDefaults{sources: appConfig.DefaultSources, ranking: appConfig.Ranking, limit: appConfig.MaxItems}
+	r.HandleFunc("/items", BuildItemsRetrieverHandler(registry, defaults)).Methods("GET")
+	r.HandleFunc("/items/stream", a.untilRetired(BuildItemsStreamHandler(registry, defaults, streamInterval))).Methods("GET")
+	// The hub looks for new items in the snapshots of the sources, fetching
+	// them on every discovery when they are neither polled nor cached.
+	if poller != nil || appConfig.CacheTTL > 0 {
+		hub := services.NewItemsHub(registry, discoveryInterval, pollMaxItems)
+		go hub.Run(ctx)
+		r.HandleFunc("/items/subscribe", a.untilRetired(BuildSubscriptionsHandler(registry, hub, heartbeatInterval))).Methods("GET")
+	} else {
+		r.HandleFunc("/items/subscribe", subscriptionsUnavailable).Methods("GET")
+	}
+	r.HandleFunc("/items/{source}/{id}/comments", BuildCommentsHandler(registry)).Methods("GET")
+	r.HandleFunc("/items/{source}/{id}/history", BuildItemHistoryHandler(registry, itemStore)).Methods("GET")
+	r.HandleFunc("/front-pages/{source}", BuildFrontPageHandler(registry, itemStore)).Methods("GET")
+	for _, route := range appConfig.Routes {
+		routeDefaults := itemsDefaults{sources: route.Sources, ranking: cmp.Or(route.Ranking, appConfig.Ranking), limit: appConfig.MaxItems}
+		if len(route.Lists) == 0 {
+			r.HandleFunc(route.Path, BuildItemsRetrieverHandler(registry, routeDefaults)).Methods("GET")
+			continue
+		}
+		listHandler := BuildHackerNewsListHandler(registry, route.Lists, route.DefaultList, routeDefaults)
+		r.HandleFunc(route.Path, listHandler).Methods("GET")
+		r.HandleFunc(route.Path+"/{list}", listHandler).Methods("GET")
+	}
+
+	r.HandleFunc("/admin/breakers", BuildBreakersStatusHandler(breakers)).Methods("GET")
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", BuildHealthHandler()).Methods("GET")
+	r.HandleFunc("/readyz", BuildReadinessHandler(registry, poller)).Methods("GET")
+	r.HandleFunc("/status/sources", BuildSourcesStatusHandler(registry)).Methods("GET")
+	r.Use(requestIdMiddleware)
+	if err := instrumentRoutes(r); err != nil {
+		a.stop()
+		return nil, fmt.Errorf("could not instrument routes: %w", err)
+	}
+	a.router, a.poller = r, poller
+	return a, nil
+}
+
+// untilRetired serves the requests of handler until the app is retired.
+func (a *app) untilRetired(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		defer context.AfterFunc(a.retiring, cancel)()
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+// newConnector builds the connector of the source of the given type, its
+// requests being retried, along with the client it owns, nil for connectors
+// sharing http.DefaultTransport.
+func newConnector(source config.SourceConfig) (services.Retriever, *http.Client) {
+	if source.Type == config.SourceLobstersScraper {
+		transport := &services.RetryTransport{Base: services.TracedTransport(nil), Policy: services.DefaultRetryPolicy, Source: source.Key}
+		return &services.WebScrapperConnector{Url: source.Url, Transport: transport}, nil
+	}
+	client := services.WithRetries(services.NewHTTPClient(services.DefaultHTTPClientConfig), services.DefaultRetryPolicy, source.Key)
+	switch source.Type {
+	case config.SourceHackerNews:
+		return &services.APIConnector{Url: source.Url, ItemsEndPoint: source.Endpoints.Items, ItemDataEndPoint: source.Endpoints.Item, Client: client,
+			MaxConcurrency: hnMaxConcurrency, DiscussionUrl: source.Endpoints.Discussion}, client
+	case config.SourceLobsters:
+		return &services.LobstersAPIConnector{Url: source.Url, EndPoint: source.Endpoints.Items, Client: client}, client
+	default:
+		return &services.FeedConnector{Url: source.Url, Client: client}, client
+	}
+}
+
+// pollSources returns a registry serving the sources of registry from the
+// snapshots of a poller, refreshing every source on its interval once
+// started, together with the poller.
+func pollSources(registry *services.SourceRegistry, appConfig config.Config) (*services.SourceRegistry, *services.Poller) {
+	intervals := make(map[string]time.Duration, len(appConfig.Sources))
+	for _, source := range appConfig.Sources {
+		intervals[source.Key] = cmp.Or(source.Interval, appConfig.PollInterval)
+	}
+	poller := services.NewPoller()
+	polledRegistry := services.NewSourceRegistry()
+	for _, key := range registry.Keys() {
+		source, _ := registry.Get(key)
+		polledRegistry.Register(key, poller.Add(key, source, intervals[key], pollMaxItems))
+	}
+	return polledRegistry, poller
+}
+
+// seedSnapshots seeds poller with the snapshots polled by previous of the
+// sources still fetching the same items, so that replacing an app does not
+// leave it without items until its first polls.
+func seedSnapshots(poller *services.Poller, appConfig config.Config, previous *app) {
+	if previous == nil || previous.poller == nil {
+		return
+	}
+	for _, source := range appConfig.Sources {
+		index := slices.IndexFunc(previous.config.Sources, func(previousSource config.SourceConfig) bool { return previousSource.Key == source.Key })
+		if index < 0 {
+			continue
+		}
+		previousSource := previous.config.Sources[index]
+		if previousSource.Type != source.Type || previousSource.Url != source.Url || previousSource.Endpoints != source.Endpoints {
+			continue
+		}
+		if snapshot, ok := previous.poller.Snapshot(source.Key); ok {
+			poller.Seed(source.Key, snapshot)
+		}
+	}
+}
+
+// cacheSources returns a registry caching the items of the sources of
+// registry for ttl, the fallback of sources fetched on request.
+func cacheSources(registry *services.SourceRegistry, ttl time.Duration) *services.SourceRegistry {
+	cacheConfig := services.DefaultCacheConfig
+	cacheConfig.TTL = ttl
+	cachedRegistry := services.NewSourceRegistry()
+	for _, key := range registry.Keys() {
+		source, _ := registry.Get(key)
+		source.Connector = services.NewCachingRetriever(source.SourceName, source.Connector, cacheConfig)
+		cachedRegistry.Register(key, source)
+	}
+	return cachedRegistry
+}
+
+// acquire counts a request served by the app, reporting false when the app
+// is retired and serves no more requests.
+func (a *app) acquire() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.retired {
+		return false
+	}
+	a.active++
+	return true
+}
+
+// release ends a request counted by acquire.
+func (a *app) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active--
+	if a.active == 0 && a.retired {
+		a.stop()
+	}
+}
+
+// retire ends the streams and subscriptions of the app, and stops its
+// background tasks once the requests it is serving are done.
+func (a *app) retire() {
+	a.endStreams()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.retired = true
+	if a.active == 0 {
+		a.stop()
+	}
+}
+
+// appHandler serves every request with the current app, replaced on
+// configuration reloads without closing the server connections.
+type appHandler struct {
+	current atomic.Pointer[app]
+}
+
+func (h *appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for {
+		// Apps are retired once replaced, the current one is then loaded again.
+		current := h.current.Load()
+		if current.acquire() {
+			defer current.release()
+			current.router.ServeHTTP(w, r)
+			return
+		}
+	}
+}
+
+// replace serves the next requests with next, retiring the previous app.
+func (h *appHandler) replace(next *app) {
+	if previous := h.current.Swap(next); previous != nil {
+		previous.retire()
+	}
+}
+
+// reload replaces the app of h by the one built from the configuration at
+// path. Invalid configurations are reported, the current app being kept.
+// The server and store settings are only applied on restart.
+func (h *appHandler) reload(path string, getenv func(string) string, itemStore store.Store) error {
+	appConfig, err := config.Load(path, getenv)
+	if err != nil {
+		return err
+	}
+	current := h.current.Load()
+	next, err := newApp(appConfig, itemStore, current)
+	if err != nil {
+		return err
+	}
+	if current != nil && (appConfig.Server != current.config.Server ||
+		appConfig.StorePath != current.config.StorePath || appConfig.StoreRetention != current.config.StoreRetention) {
+		slog.Warn("Server and store settings changed, they will be applied on restart")
+	}
+	h.replace(next)
+	slog.Info("Configuration reloaded", "config", path, "sources", len(appConfig.Sources), "routes", len(appConfig.Routes))
+	return nil
+}
+
+// reloadOnHangup reloads the configuration at path on every SIGHUP.
+func reloadOnHangup(path string, handler *appHandler, itemStore store.Store) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for range hangups {
+		if err := handler.reload(path, os.Getenv, itemStore); err != nil {
+			slog.Error("Failed to reload configuration, keeping the current one", "config", path, "error", err)
+		}
+	}
+}
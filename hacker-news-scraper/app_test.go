@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/config"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/store"
+	"github.com/gorilla/websocket"
+)
+
+// testApp returns an app serving router, stopped with stop.
+func testApp(router http.Handler, stop func()) *app {
+	a := &app{router: router, stop: stop}
+	a.retiring, a.endStreams = context.WithCancel(context.Background())
+	return a
+}
+
+func TestAppHandlerReplace(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var stopped atomic.Int32
+	first := testApp(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("first"))
+	}), func() { stopped.Add(1) })
+	second := testApp(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("second"))
+	}), func() {})
+	handler := &appHandler{}
+	handler.replace(first)
+
+	streaming := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(streaming, httptest.NewRequest("GET", "/items/stream", nil))
+		close(done)
+	}()
+	<-started
+	handler.replace(second)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/items", nil))
+	if rr.Body.String() != "second" {
+		t.Errorf("request served by %q, want the replacing app", rr.Body.String())
+	}
+	if stopped.Load() != 0 {
+		t.Errorf("replaced app stopped while serving a request")
+	}
+
+	close(release)
+	<-done
+	if streaming.Body.String() != "first" {
+		t.Errorf("open request served by %q, want the replaced app", streaming.Body.String())
+	}
+	if got := stopped.Load(); got != 1 {
+		t.Errorf("replaced app stopped %d times, want once its requests are done", got)
+	}
+	if first.acquire() {
+		t.Errorf("replaced app accepted a request")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"https://jsonfeed.org/version/1.1","items":[{"id":"1","url":"https://example.com/1","title":"Feed story"}]}`))
+	}))
+	defer feed.Close()
+	itemStore, err := store.OpenBoltStore(filepath.Join(t.TempDir(), "items.db"))
+	if err != nil {
+		t.Fatalf("could not open item store: %v", err)
+	}
+	defer itemStore.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(route string) {
+		body := "poll_interval: 1h\nsources:\n  - key: feed\n    type: feed\n    url: " + feed.URL + "\nroutes:\n  - path: " + route + "\n    sources: [feed]\n"
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("could not write configuration: %v", err)
+		}
+	}
+	getenv := func(string) string { return "" }
+	handler := &appHandler{}
+
+	steps := []struct {
+		name       string
+		config     string
+		wantErr    bool
+		wantReady  bool
+		wantRoutes map[string]int
+	}{
+		{name: "Initial routes", config: "/feed-items", wantRoutes: map[string]int{"/feed-items": http.StatusOK, "/items": http.StatusOK}},
+		// The polled items are carried over, so the reloaded app is ready.
+		{name: "Reloaded routes", config: "/other-items", wantReady: true, wantRoutes: map[string]int{"/other-items": http.StatusOK, "/feed-items": http.StatusNotFound}},
+		{name: "Invalid configuration keeps routes", config: "/items/other", wantErr: true, wantRoutes: map[string]int{"/other-items": http.StatusOK}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			writeConfig(step.config)
+			if err := handler.reload(path, getenv, itemStore); (err != nil) != step.wantErr {
+				t.Fatalf("reload() error = %v, wantErr %v", err, step.wantErr)
+			}
+			if step.wantReady {
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+				if rr.Code != http.StatusOK {
+					t.Errorf("/readyz returned %v after reloading, want %v", rr.Code, http.StatusOK)
+				}
+			}
+			for route, wantStatus := range step.wantRoutes {
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, httptest.NewRequest("GET", route, nil))
+				if rr.Code != wantStatus {
+					t.Errorf("%s returned wrong status code: got %v want %v", route, rr.Code, wantStatus)
+				}
+				if wantStatus == http.StatusOK && !strings.Contains(rr.Body.String(), "Feed story") {
+					t.Errorf("%s returned %s, want the feed items", route, rr.Body.String())
+				}
+			}
+		})
+	}
+
+	// Streams and subscriptions end on reloads, their clients reconnecting.
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	stream, err := http.Get(server.URL + "/items/stream")
+	if err != nil {
+		t.Fatalf("could not open stream: %v", err)
+	}
+	defer stream.Body.Close()
+	subscription, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/items/subscribe", nil)
+	if err != nil {
+		t.Fatalf("could not open subscription: %v", err)
+	}
+	defer subscription.Close()
+	writeConfig("/other-items")
+	if err := handler.reload(path, getenv, itemStore); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	ended := make(chan error)
+	go func() {
+		_, err := io.ReadAll(stream.Body)
+		ended <- err
+	}()
+	select {
+	case err := <-ended:
+		if err != nil {
+			t.Errorf("stream ended with %v, want it closed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("stream still open after reloading")
+	}
+	subscription.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := subscription.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				t.Errorf("subscription ended with %v, want it closed", err)
+			}
+			break
+		}
+	}
+	handler.current.Load().retire()
+}
+
+func TestSubscriptionsUnavailable(t *testing.T) {
+	itemStore, err := store.OpenBoltStore(filepath.Join(t.TempDir(), "items.db"))
+	if err != nil {
+		t.Fatalf("could not open item store: %v", err)
+	}
+	defer itemStore.Close()
+	appConfig := config.Default()
+	appConfig.PollInterval, appConfig.CacheTTL = 0, 0
+	a, err := newApp(appConfig, itemStore, nil)
+	if err != nil {
+		t.Fatalf("newApp() error = %v", err)
+	}
+	defer a.stop()
+
+	rr := httptest.NewRecorder()
+	a.router.ServeHTTP(rr, httptest.NewRequest("GET", "/items/subscribe", nil))
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "subscriptions_unavailable") {
+		t.Errorf("subscription answered with %d %s, want %d", rr.Code, rr.Body.String(), http.StatusServiceUnavailable)
+	}
+}
+
+func TestRetiredAppClosesSourceConnections(t *testing.T) {
+	var closed atomic.Int32
+	feed := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"https://jsonfeed.org/version/1.1","items":[{"id":"1","url":"https://example.com/1","title":"Feed story"}]}`))
+	}))
+	feed.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed.Add(1)
+		}
+	}
+	feed.Start()
+	defer feed.Close()
+	itemStore, err := store.OpenBoltStore(filepath.Join(t.TempDir(), "items.db"))
+	if err != nil {
+		t.Fatalf("could not open item store: %v", err)
+	}
+	defer itemStore.Close()
+	appConfig := config.Default()
+	appConfig.PollInterval, appConfig.CacheTTL = 0, 0
+	appConfig.Sources = []config.SourceConfig{{Key: "feed", Type: config.SourceFeed, Url: feed.URL}}
+	appConfig.DefaultSources = []string{"feed"}
+	a, err := newApp(appConfig, itemStore, nil)
+	if err != nil {
+		t.Fatalf("newApp() error = %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	a.router.ServeHTTP(rr, httptest.NewRequest("GET", "/items", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("items answered with %d, want %d", rr.Code, http.StatusOK)
+	}
+	a.retire()
+	deadline := time.Now().Add(2 * time.Second)
+	for closed.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if closed.Load() == 0 {
+		t.Errorf("connections to the source still open after retiring the app")
+	}
+}
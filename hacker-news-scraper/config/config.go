@@ -0,0 +1,450 @@
+// Package config loads the configuration of the scraper: the server, the
+// sources and the routes composed from them, read from a YAML file and
+// overridden by environment variables.
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	"gopkg.in/yaml.v3"
+)
+
+// Source types, choosing the connector of a source.
+const (
+	SourceHackerNews      = "hacker-news"
+	SourceLobsters        = "lobsters"
+	SourceLobstersScraper = "lobsters-scraper"
+	SourceFeed            = "feed"
+)
+
+var sourceTypes = []string{SourceHackerNews, SourceLobsters, SourceLobstersScraper, SourceFeed}
+
+// MaxLimit is the largest number of items a request may ask for, the
+// max_items returned to requests setting no limit included.
+const MaxLimit = 200
+
+// reservedPaths are the prefixes of the built-in routes, which configured
+// routes cannot take.
+var reservedPaths = []string{"/items", "/front-pages", "/admin", "/metrics", "/healthz", "/readyz", "/status", "/debug"}
+
+type Config struct {
+	Server ServerConfig `yaml:"server"`
+	// StorePath is the path of the database recording the fetched items.
+	StorePath string `yaml:"store_path"`
+	// StoreRetention is how long the fetched items are kept in the store.
+	// Zero keeps them forever.
+	StoreRetention time.Duration `yaml:"store_retention"`
+	// PollInterval is how often the sources are polled in the background.
+	// Zero fetches them on request instead, caching their items for CacheTTL.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// CacheTTL is how long the items of sources fetched on request are
+	// cached. Zero disables the cache.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	// MaxItems is the number of items returned to requests setting no limit.
+	MaxItems int `yaml:"max_items"`
+	// Ranking is the ranking of the items of requests setting none.
+	Ranking string `yaml:"ranking"`
+	// DefaultSources are the sources aggregated by /items when requests
+	// select none. Every source is aggregated when empty.
+	DefaultSources []string       `yaml:"default_sources"`
+	Sources        []SourceConfig `yaml:"sources"`
+	Routes         []RouteConfig  `yaml:"routes"`
+}
+
+type ServerConfig struct {
+	// Address is the address the server listens on, such as ":8080".
+	Address           string        `yaml:"address"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	// WriteTimeout bounds streams and subscriptions too, so it is disabled
+	// by default.
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+}
+
+type SourceConfig struct {
+	// Key identifies the source in requests, such as "hn".
+	Key  string `yaml:"key"`
+	Type string `yaml:"type"`
+	// Name is the name of the source in responses, its key when empty.
+	Name      string    `yaml:"name"`
+	Url       string    `yaml:"url"`
+	Endpoints Endpoints `yaml:"endpoints"`
+	// Interval is how often the source is polled, PollInterval when zero.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout bounds the fetches of the source. Zero leaves them unbounded.
+	Timeout time.Duration `yaml:"timeout"`
+	// Weight is the share of the items of an aggregation given to the
+	// source, relative to the other sources. Defaults to 1.
+	Weight float64 `yaml:"weight"`
+}
+
+// Endpoints are the paths of the source API, relative to its URL.
+type Endpoints struct {
+	// Items is the list of items of the source, such as "topstories" for
+	// Hacker News or "newest" for Lobsters.
+	Items string `yaml:"items"`
+	// Item is the Hacker News item data endpoint.
+	Item string `yaml:"item"`
+	// Discussion is the format, taking the item id, of the web page of a
+	// Hacker News item.
+	Discussion string `yaml:"discussion"`
+}
+
+// RouteConfig describes a route serving the items of some sources, such as
+// /combine-sources-items. A route with lists serves at Path, and at
+// Path/{list}, the source of the list chosen by requests instead.
+type RouteConfig struct {
+	Path    string   `yaml:"path"`
+	Sources []string `yaml:"sources"`
+	// Lists maps every list name, such as "new", to the key of its source.
+	Lists       map[string]string `yaml:"lists"`
+	DefaultList string            `yaml:"default_list"`
+	// Ranking is the ranking of the route, the default one when empty.
+	Ranking string `yaml:"ranking"`
+}
+
+// Default returns the configuration used when no file is given: the Hacker
+// News lists, Lobsters and the routes combining them.
+func Default() Config {
+	config := Config{
+		Server:         ServerConfig{Address: ":8080", ReadHeaderTimeout: 10 * time.Second, IdleTimeout: 2 * time.Minute},
+		StorePath:      "items.db",
+		StoreRetention: 30 * 24 * time.Hour,
+		PollInterval:   time.Minute,
+		CacheTTL:       services.DefaultCacheConfig.TTL,
+		MaxItems:       30,
+		Ranking:        services.DefaultRanking,
+		DefaultSources: []string{"hn", "lobsters"},
+	}
+	lists := map[string]string{}
+	for _, list := range []struct{ name, title, endpoint string }{
+		{name: "top", title: "Top", endpoint: "topstories"},
+		{name: "new", title: "New", endpoint: "newstories"},
+		{name: "best", title: "Best", endpoint: "beststories"},
+		{name: "ask", title: "Ask", endpoint: "askstories"},
+		{name: "show", title: "Show", endpoint: "showstories"},
+		{name: "job", title: "Jobs", endpoint: "jobstories"},
+	} {
+		key, name := "hn", "Hacker News"
+		if list.name != "top" {
+			key, name = "hn-"+list.name, name+" "+list.title
+		}
+		config.Sources = append(config.Sources, SourceConfig{Key: key, Type: SourceHackerNews, Name: name, Url: "https://hacker-news.firebaseio.com/v0",
+			Endpoints: Endpoints{Items: list.endpoint, Item: "item", Discussion: "https://news.ycombinator.com/item?id=%d"}, Timeout: 10 * time.Second})
+		lists[list.name] = key
+	}
+	config.Sources = append(config.Sources, SourceConfig{Key: "lobsters", Type: SourceLobsters, Name: "Lobsters", Url: "https://lobste.rs/",
+		Endpoints: Endpoints{Items: services.LobstersHottest}, Timeout: 8 * time.Second})
+	config.Routes = []RouteConfig{
+		{Path: "/hacker-news-items", Lists: lists, DefaultList: "top"},
+		{Path: "/lobsters-items", Sources: []string{"lobsters"}},
+		{Path: "/combine-sources-items", Sources: []string{"hn", "lobsters"}},
+	}
+	return config
+}
+
+// Load reads the configuration file at path, the default configuration
+// when path is empty, applies the overrides of the environment variables
+// read through getenv and validates the result.
+func Load(path string, getenv func(string) string) (Config, error) {
+	config := Default()
+	if path != "" {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err := decode(body, &config); err != nil {
+			return config, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := config.applyEnv(getenv); err != nil {
+		return config, err
+	}
+	if len(config.DefaultSources) == 0 {
+		for _, source := range config.Sources {
+			config.DefaultSources = append(config.DefaultSources, source.Key)
+		}
+	}
+	return config, config.Validate()
+}
+
+// decode reads the YAML document body over config, rejecting unknown
+// fields. The default routes and sources are dropped along with the default
+// sources list when the document lists its own sources.
+func decode(body []byte, config *Config) error {
+	var document Config
+	if err := strictUnmarshal(body, &document); err != nil {
+		return err
+	}
+	if document.Sources != nil {
+		config.Routes, config.DefaultSources = nil, nil
+	}
+	return strictUnmarshal(body, config)
+}
+
+func strictUnmarshal(body []byte, out any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// applyEnv overrides the configuration with the environment variables set:
+// LISTEN_ADDRESS, STORE_PATH, STORE_RETENTION, POLL_INTERVAL, CACHE_TTL, MAX_ITEMS, RANKING,
+// POLL_INTERVALS as comma separated key=duration pairs such as
+// "hn=30s,lobsters=2m", LOBSTERS_CONNECTOR as api or scraper, and
+// FEED_SOURCES as comma separated key=url pairs added as feed sources.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	var errs []error
+	if address := getenv("LISTEN_ADDRESS"); address != "" {
+		c.Server.Address = address
+	}
+	if storePath := getenv("STORE_PATH"); storePath != "" {
+		c.StorePath = storePath
+	}
+	durations := []struct {
+		name   string
+		target *time.Duration
+	}{{name: "STORE_RETENTION", target: &c.StoreRetention}, {name: "POLL_INTERVAL", target: &c.PollInterval}, {name: "CACHE_TTL", target: &c.CacheTTL}}
+	for _, duration := range durations {
+		if value := getenv(duration.name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				errs = append(errs, fmt.Errorf("invalid %s %q, expected a duration such as 1m", duration.name, value))
+				continue
+			}
+			*duration.target = parsed
+		}
+	}
+	if value := getenv("MAX_ITEMS"); value != "" {
+		maxItems, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid MAX_ITEMS %q, expected a number", value))
+		} else {
+			c.MaxItems = maxItems
+		}
+	}
+	if ranking := getenv("RANKING"); ranking != "" {
+		c.Ranking = ranking
+	}
+
+	for _, pair := range splitList(getenv("POLL_INTERVALS")) {
+		key, value, ok := strings.Cut(pair, "=")
+		interval, err := time.ParseDuration(value)
+		source := c.source(key)
+		switch {
+		case !ok || key == "" || err != nil || interval <= 0:
+			errs = append(errs, fmt.Errorf("invalid poll interval %q, expected key=duration", pair))
+		case source == nil:
+			errs = append(errs, fmt.Errorf("unknown source %q in POLL_INTERVALS", key))
+		default:
+			source.Interval = interval
+		}
+	}
+	if connector := getenv("LOBSTERS_CONNECTOR"); connector != "" {
+		source := c.source("lobsters")
+		switch {
+		case source == nil:
+			errs = append(errs, errors.New("LOBSTERS_CONNECTOR set without lobsters source"))
+		case connector == "api":
+			source.Type = SourceLobsters
+		case connector == "scraper":
+			source.Type = SourceLobstersScraper
+		default:
+			errs = append(errs, fmt.Errorf("unknown LOBSTERS_CONNECTOR %q, expected api or scraper", connector))
+		}
+	}
+	for _, feed := range splitList(getenv("FEED_SOURCES")) {
+		key, feedUrl, ok := strings.Cut(feed, "=")
+		if !ok || key == "" || feedUrl == "" {
+			errs = append(errs, fmt.Errorf("invalid feed source %q, expected key=url", feed))
+			continue
+		}
+		c.Sources = append(c.Sources, SourceConfig{Key: key, Type: SourceFeed, Url: feedUrl, Timeout: 8 * time.Second})
+		if len(c.DefaultSources) > 0 {
+			c.DefaultSources = append(c.DefaultSources, key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) source(key string) *SourceConfig {
+	for i := range c.Sources {
+		if c.Sources[i].Key == key {
+			return &c.Sources[i]
+		}
+	}
+	return nil
+}
+
+// Validate reports every invalid setting of the configuration.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if c.Server.Address == "" {
+		invalid("server address is required")
+	}
+	durations := []struct {
+		name     string
+		duration time.Duration
+	}{
+		{name: "server read_header_timeout", duration: c.Server.ReadHeaderTimeout}, {name: "server read_timeout", duration: c.Server.ReadTimeout},
+		{name: "server write_timeout", duration: c.Server.WriteTimeout}, {name: "server idle_timeout", duration: c.Server.IdleTimeout},
+		{name: "store_retention", duration: c.StoreRetention}, {name: "poll_interval", duration: c.PollInterval}, {name: "cache_ttl", duration: c.CacheTTL},
+	}
+	for _, duration := range durations {
+		if duration.duration < 0 {
+			invalid("%s must not be negative", duration.name)
+		}
+	}
+	if c.StorePath == "" {
+		invalid("store_path is required")
+	}
+	if c.MaxItems < 1 || c.MaxItems > MaxLimit {
+		invalid("max_items must be between 1 and %d", MaxLimit)
+	}
+	if err := validRanking(c.Ranking); err != nil {
+		invalid("ranking: %v", err)
+	}
+
+	if len(c.Sources) == 0 {
+		invalid("at least one source is required")
+	}
+	keys := make(map[string]bool, len(c.Sources))
+	names := make(map[string]bool, len(c.Sources))
+	for i, source := range c.Sources {
+		if source.Key == "" || strings.Contains(source.Key, ",") {
+			invalid("sources[%d]: invalid key %q", i, source.Key)
+		} else if keys[source.Key] {
+			invalid("sources[%d]: duplicate key %q", i, source.Key)
+		}
+		keys[source.Key] = true
+		// Responses tell sources apart by name, the key of unnamed ones.
+		if name := cmp.Or(source.Name, source.Key); names[name] {
+			invalid("sources[%d]: duplicate name %q", i, name)
+		} else {
+			names[name] = true
+		}
+		for _, err := range source.validate() {
+			invalid("source %q: %v", source.Key, err)
+		}
+	}
+	if err := knownSources(c.DefaultSources, keys); err != nil {
+		invalid("default_sources: %v", err)
+	}
+
+	paths := make(map[string]bool, len(c.Routes))
+	for i, route := range c.Routes {
+		if !strings.HasPrefix(route.Path, "/") || strings.ContainsAny(route.Path, "{}") {
+			invalid("routes[%d]: invalid path %q, expected a path such as /my-items", i, route.Path)
+		} else if paths[route.Path] {
+			invalid("routes[%d]: duplicate path %q", i, route.Path)
+		} else if reserved := reservedPath(route.Path); reserved != "" {
+			invalid("routes[%d]: path %q conflicts with the built-in %s routes", i, route.Path, reserved)
+		}
+		paths[route.Path] = true
+		for _, err := range route.validate(keys) {
+			invalid("route %q: %v", route.Path, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s SourceConfig) validate() []error {
+	var errs []error
+	if !slices.Contains(sourceTypes, s.Type) {
+		errs = append(errs, fmt.Errorf("unknown type %q, expected one of %s", s.Type, strings.Join(sourceTypes, ", ")))
+	}
+	if parsed, err := url.Parse(s.Url); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid url %q, expected an http or https URL", s.Url))
+	}
+	if s.Type == SourceHackerNews && (s.Endpoints.Items == "" || s.Endpoints.Item == "") {
+		errs = append(errs, errors.New("endpoints items and item are required"))
+	}
+	if s.Interval < 0 || s.Timeout < 0 {
+		errs = append(errs, errors.New("interval and timeout must not be negative"))
+	}
+	if s.Weight < 0 {
+		errs = append(errs, errors.New("weight must not be negative"))
+	}
+	return errs
+}
+
+func (r RouteConfig) validate(keys map[string]bool) []error {
+	var errs []error
+	switch {
+	case len(r.Sources) == 0 && len(r.Lists) == 0:
+		errs = append(errs, errors.New("sources or lists are required"))
+	case len(r.Sources) > 0 && len(r.Lists) > 0:
+		errs = append(errs, errors.New("sources and lists cannot be combined"))
+	case len(r.Lists) > 0:
+		if _, ok := r.Lists[r.DefaultList]; !ok {
+			errs = append(errs, fmt.Errorf("default_list %q is not one of its lists", r.DefaultList))
+		}
+	}
+	if err := knownSources(r.Sources, keys); err != nil {
+		errs = append(errs, err)
+	}
+	for name, key := range r.Lists {
+		if !keys[key] {
+			errs = append(errs, fmt.Errorf("unknown source %q of list %q", key, name))
+		}
+	}
+	if r.Ranking != "" {
+		if err := validRanking(r.Ranking); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func knownSources(sources []string, keys map[string]bool) error {
+	for _, key := range sources {
+		if !keys[key] {
+			return fmt.Errorf("unknown source %q", key)
+		}
+	}
+	return nil
+}
+
+func validRanking(ranking string) error {
+	if _, ok := services.Rankers[ranking]; !ok {
+		return fmt.Errorf("unknown ranking %q, available rankings: %s", ranking, strings.Join(services.RankerNames(), ", "))
+	}
+	return nil
+}
+
+func reservedPath(path string) string {
+	for _, reserved := range reservedPaths {
+		if path == reserved || strings.HasPrefix(path, reserved+"/") {
+			return reserved
+		}
+	}
+	return ""
+}
+
+func splitList(value string) []string {
+	values := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	sources := `
+sources:
+  - key: goblog
+    type: feed
+    url: https://go.dev/blog/feed.atom
+    interval: 1h
+    weight: 0.5
+  - key: lobsters
+    type: lobsters
+    url: https://lobste.rs/
+`
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		check   func(t *testing.T, config Config)
+		wantErr []string
+	}{
+		{name: "Defaults", check: func(t *testing.T, config Config) {
+			if config.Server.Address != ":8080" || config.StoreRetention != 30*24*time.Hour || config.MaxItems != 30 || len(config.Sources) != 7 || len(config.Routes) != 3 {
+				t.Errorf("Load() = %+v, want the default configuration", config)
+			}
+		}},
+		{name: "File settings", file: "server:\n  address: :9090\nranking: score\n", check: func(t *testing.T, config Config) {
+			if config.Server.Address != ":9090" || config.Server.IdleTimeout != 2*time.Minute || config.Ranking != "score" || len(config.Sources) != 7 {
+				t.Errorf("Load() = %+v, want the file settings over the defaults", config)
+			}
+		}},
+		{name: "File sources", file: sources, check: func(t *testing.T, config Config) {
+			if len(config.Sources) != 2 || config.Sources[0].Interval != time.Hour || config.Sources[0].Weight != 0.5 {
+				t.Errorf("Load() sources = %+v, want the file sources", config.Sources)
+			}
+			if len(config.Routes) != 0 || !slices.Equal(config.DefaultSources, []string{"goblog", "lobsters"}) {
+				t.Errorf("Load() = %+v, want no routes and every source by default", config)
+			}
+		}},
+		{name: "Environment overrides", file: sources, env: map[string]string{"LISTEN_ADDRESS": ":9090", "STORE_RETENTION": "0s", "POLL_INTERVAL": "0s", "POLL_INTERVALS": "lobsters=2m",
+			"LOBSTERS_CONNECTOR": "scraper", "FEED_SOURCES": "rust=https://blog.rust-lang.org/feed.xml", "MAX_ITEMS": "50"}, check: func(t *testing.T, config Config) {
+			if config.Server.Address != ":9090" || config.StoreRetention != 0 || config.PollInterval != 0 || config.MaxItems != 50 {
+				t.Errorf("Load() = %+v, want the environment settings", config)
+			}
+			if lobsters := config.Sources[1]; lobsters.Interval != 2*time.Minute || lobsters.Type != SourceLobstersScraper {
+				t.Errorf("Load() lobsters = %+v, want the environment settings", lobsters)
+			}
+			if feed := config.Sources[2]; feed.Key != "rust" || feed.Type != SourceFeed || !slices.Contains(config.DefaultSources, "rust") {
+				t.Errorf("Load() = %+v, want the feed source added", config)
+			}
+		}},
+		{name: "Unknown field", file: "server:\n  adress: :9090\n", wantErr: []string{"field adress not found"}},
+		{name: "Malformed duration", file: "poll_interval: often\n", wantErr: []string{"often"}},
+		{name: "Invalid environment", env: map[string]string{"CACHE_TTL": "-1m", "POLL_INTERVALS": "reddit=1m", "LOBSTERS_CONNECTOR": "rss"},
+			wantErr: []string{"invalid CACHE_TTL", `unknown source "reddit" in POLL_INTERVALS`, `unknown LOBSTERS_CONNECTOR "rss"`}},
+		{name: "Invalid sources", file: `
+sources:
+  - key: hn
+    type: hacker-news
+    url: https://hacker-news.firebaseio.com/v0
+  - key: hn
+    type: reddit
+    url: reddit.com
+    weight: -1
+  - key: lobsters
+    type: lobsters
+    name: hn
+    url: https://lobste.rs/
+`, wantErr: []string{`source "hn": endpoints items and item are required`, `sources[1]: duplicate key "hn"`, `sources[2]: duplicate name "hn"`, `unknown type "reddit"`, `invalid url "reddit.com"`, "weight must not be negative"}},
+		{name: "Invalid routes", file: sources + `
+routes:
+  - path: /items/top
+    sources: [goblog]
+  - path: lobsters
+    sources: [lobsters]
+    ranking: hot
+  - path: /lists
+    lists:
+      top: hn
+  - path: /empty
+`, wantErr: []string{`path "/items/top" conflicts with the built-in /items routes`, `invalid path "lobsters"`, `unknown ranking "hot"`,
+			`unknown source "hn" of list "top"`, `default_list "" is not one of its lists`, `route "/empty": sources or lists are required`}},
+		{name: "Invalid settings", file: "max_items: 0\nranking: hot\ndefault_sources: [reddit]\nserver:\n  address: ''\n  idle_timeout: -1s\n",
+			wantErr: []string{"max_items must be between 1 and 200", `ranking: unknown ranking "hot"`, `default_sources: unknown source "reddit"`,
+				"server address is required", "server idle_timeout must not be negative"}},
+		{name: "Too many items", file: "max_items: 201\n", wantErr: []string{"max_items must be between 1 and 200"}},
+		{name: "Negative retention", file: "store_retention: -1h\n", wantErr: []string{"store_retention must not be negative"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatalf("could not write configuration: %v", err)
+				}
+			}
+			config, err := Load(path, func(name string) string { return tt.env[name] })
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() error = %v, want it to contain %q", err, want)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, config)
+			}
+		})
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	config, err := Load("../config.example.yaml", func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(config.Sources) != 4 || len(config.Routes) != 4 {
+		t.Errorf("Load() = %+v, want the example sources and routes", config)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), func(string) string { return "" }); !os.IsNotExist(err) {
+		t.Errorf("Load() error = %v, want a missing file error", err)
+	}
+}
@@ -0,0 +1,18 @@
+package data
+
+type Comment struct {
+	Id      string    `json:"id"`
+	By      string    `json:"by"`
+	Time    int       `json:"time"`
+	Text    string    `json:"text"`
+	Deleted bool      `json:"deleted,omitempty"`
+	Dead    bool      `json:"dead,omitempty"`
+	Replies []Comment `json:"replies,omitempty"`
+}
+
+type CommentThread struct {
+	StoryId  string    `json:"story_id"`
+	Comments []Comment `json:"comments"`
+	// Truncated flags threads cut by the depth or size limits.
+	Truncated bool `json:"truncated"`
+}
@@ -12,4 +12,31 @@ type Item struct {
 	Title       string `json:"title"`
 	Type        string `json:"type"`
 	Url         string `json:"url"`
+	// Text is the HTML body of Ask HN stories, jobs, polls and comments.
+	Text    string `json:"text,omitempty"`
+	Parts   []int  `json:"parts,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Dead    bool   `json:"dead,omitempty"`
+	// PollOptions holds the resolved Parts of poll items.
+	PollOptions []PollOption `json:"poll_options,omitempty"`
+	// ShortId is the identifier of sources using alphanumeric ids, such as
+	// Lobsters, whose Id holds the same value decoded from base 36.
+	ShortId string   `json:"short_id,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	// Sources lists where the item was found when the aggregator keeps track
+	// of provenance, one entry per source for merged duplicates.
+	Sources []ItemSource `json:"sources,omitempty"`
+}
+
+type ItemSource struct {
+	Source   string `json:"source"`
+	Id       ItemId `json:"id"`
+	Url      string `json:"url,omitempty"`
+	Score    int    `json:"score"`
+	Comments int    `json:"comments"`
+}
+
+type PollOption struct {
+	Text  string `json:"text"`
+	Score int    `json:"score"`
 }
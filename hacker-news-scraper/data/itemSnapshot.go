@@ -0,0 +1,28 @@
+package data
+
+import "time"
+
+// ItemSnapshot is the state of an item as seen by one fetch of its source.
+type ItemSnapshot struct {
+	Id        string    `json:"id"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// Rank is the 1-based position of the item in the source listing.
+	Rank     int    `json:"rank"`
+	Title    string `json:"title"`
+	Url      string `json:"url"`
+	Score    int    `json:"score"`
+	Comments int    `json:"comments"`
+}
+
+type ItemHistory struct {
+	Source    string         `json:"source"`
+	Id        string         `json:"id"`
+	Snapshots []ItemSnapshot `json:"snapshots"`
+}
+
+// FrontPage is the listing of a source as returned by one of its fetches.
+type FrontPage struct {
+	Source    string         `json:"source"`
+	FetchedAt time.Time      `json:"fetched_at"`
+	Items     []ItemSnapshot `json:"items"`
+}
@@ -0,0 +1,33 @@
+package data
+
+type RankingEventType string
+
+const (
+	// RankingSnapshot carries the whole ranking, sent when a stream starts.
+	RankingSnapshot   RankingEventType = "ranking"
+	ItemEntered       RankingEventType = "entered"
+	ItemLeft          RankingEventType = "left"
+	ItemMoved         RankingEventType = "moved"
+	ScoreThreshold    RankingEventType = "score_threshold"
+	CommentsThreshold RankingEventType = "comments_threshold"
+)
+
+// RankingEvent describes a change of an item between two successive
+// rankings of the aggregated sources.
+type RankingEvent struct {
+	Type RankingEventType `json:"type"`
+	// Source is the name of the first source listing the item, empty when
+	// not tracked.
+	Source string `json:"source,omitempty"`
+	Id     string `json:"id"`
+	Title  string `json:"title"`
+	Url    string `json:"url"`
+	// Rank is the 1-based position of the item, 0 for items that left the
+	// ranking, and PreviousRank its former position, 0 for entering items.
+	Rank         int `json:"rank"`
+	PreviousRank int `json:"previous_rank"`
+	Score        int `json:"score"`
+	Comments     int `json:"comments"`
+	// Threshold is the score or comments threshold crossed by the item.
+	Threshold int `json:"threshold,omitempty"`
+}
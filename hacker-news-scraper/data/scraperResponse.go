@@ -1,10 +1,16 @@
 package data
 
 type ScraperResponse struct {
-	Order    int    `json:"order"`
-	Id       string `json:"id"`
-	Title    string `json:"title"`
-	Url      string `json:"url"`
-	Comments int    `json:"comments"`
-	Score    int    `json:"score"`
+	Order    int          `json:"order"`
+	Id       string       `json:"id"`
+	Title    string       `json:"title"`
+	Url      string       `json:"url"`
+	Comments int          `json:"comments"`
+	Score    int          `json:"score"`
+	Sources  []ItemSource `json:"sources,omitempty"`
+	// Type is the item kind (story, job, poll) and Text its body when the
+	// item has no link of its own.
+	Type        string       `json:"type,omitempty"`
+	Text        string       `json:"text,omitempty"`
+	PollOptions []PollOption `json:"poll_options,omitempty"`
 }
@@ -0,0 +1,32 @@
+package data
+
+import "time"
+
+// SourceHealth reports the outcome of the last fetches of a registered
+// source, as served by the sources status endpoint.
+type SourceHealth struct {
+	Source       string     `json:"source"`
+	Name         string     `json:"name"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+	LastFailure  *time.Time `json:"last_failure,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LatencyMs    int64      `json:"latency_ms"`
+	Items        int        `json:"items"`
+	BreakerState string     `json:"breaker_state,omitempty"`
+}
+
+// HealthResponse is the body of the health and readiness endpoints.
+type HealthResponse struct {
+	Status string `json:"status"`
+	// Sources report the readiness of polled sources.
+	Sources []SourceReadiness `json:"sources,omitempty"`
+}
+
+type SourceReadiness struct {
+	Source string `json:"source"`
+	// Polled is set once the source has been polled, successfully or not.
+	Polled bool `json:"polled"`
+	// Fresh is set while the source serves recently fetched items.
+	Fresh     bool       `json:"fresh"`
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+}
@@ -0,0 +1,22 @@
+package data
+
+import "time"
+
+type SourceState string
+
+const (
+	SourceOk       SourceState = "ok"
+	SourceFailed   SourceState = "failed"
+	SourceTimedOut SourceState = "timed_out"
+	// SourceSkipped flags a source not called because its circuit is open.
+	SourceSkipped SourceState = "skipped"
+)
+
+type SourceStatus struct {
+	Source    string      `json:"source"`
+	State     SourceState `json:"status"`
+	Error     string      `json:"error,omitempty"`
+	LatencyMs int64       `json:"latency_ms"`
+	// FetchedAt is when the items of a successful source were fetched.
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+}
@@ -0,0 +1,30 @@
+package data
+
+import "time"
+
+type SubscriptionFilters struct {
+	// Sources are the keys of the sources followed, all of them when empty.
+	Sources  []string `json:"sources,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	MinScore int      `json:"min_score,omitempty"`
+	Domains  []string `json:"domains,omitempty"`
+}
+
+// SubscriptionRequest is a message sent by subscription clients. A
+// "subscribe" request starts the subscription or replaces its filters.
+type SubscriptionRequest struct {
+	Type    string              `json:"type"`
+	Filters SubscriptionFilters `json:"filters"`
+}
+
+// SubscriptionMessage is a message sent to subscription clients: the
+// "subscribed" acknowledgement of their filters, a new "item", an "error"
+// or a "heartbeat".
+type SubscriptionMessage struct {
+	Type    string               `json:"type"`
+	Source  string               `json:"source,omitempty"`
+	Item    *ScraperResponse     `json:"item,omitempty"`
+	Filters *SubscriptionFilters `json:"filters,omitempty"`
+	Error   *ErrorDetail         `json:"error,omitempty"`
+	Time    *time.Time           `json:"time,omitempty"`
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+const defaultFormat = "json"
+
+// itemsPage describes the ranked items of a response to the formats
+// rendering them as a document, such as feeds and HTML pages.
+type itemsPage struct {
+	Title   string
+	Link    string
+	Updated time.Time
+	Items   []data.ScraperResponse
+}
+
+// responseFormat renders the items of a response in one of the formats
+// available through the format parameter or the Accept header.
+type responseFormat struct {
+	name        string
+	contentType string
+	// mediaTypes are the Accept media types selecting the format.
+	mediaTypes []string
+	encode     func(w io.Writer, page itemsPage) error
+}
+
+var responseFormats = []responseFormat{
+	{name: "json", contentType: "application/json", mediaTypes: []string{"application/json"}, encode: encodeJSON},
+	{name: "ndjson", contentType: "application/x-ndjson", mediaTypes: []string{"application/x-ndjson", "application/ndjson", "application/jsonl"}, encode: encodeNDJSON},
+	{name: "csv", contentType: "text/csv; charset=utf-8", mediaTypes: []string{"text/csv"}, encode: encodeCSV},
+	{name: "rss", contentType: "application/rss+xml; charset=utf-8", mediaTypes: []string{"application/rss+xml"}, encode: encodeRSS},
+	{name: "atom", contentType: "application/atom+xml; charset=utf-8", mediaTypes: []string{"application/atom+xml"}, encode: encodeAtom},
+	{name: "html", contentType: "text/html; charset=utf-8", mediaTypes: []string{"text/html"}, encode: encodeHTML},
+}
+
+// negotiateFormat picks the response format from the format parameter or,
+// when absent, the preferred media type of the Accept header. JSON is used
+// when the request expresses no preference.
+func negotiateFormat(r *http.Request) (responseFormat, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, format := range responseFormats {
+			if format.name == name {
+				return format, nil
+			}
+		}
+		names := make([]string, len(responseFormats))
+		for i, format := range responseFormats {
+			names[i] = format.name
+		}
+		message := fmt.Sprintf("unknown format %q, available formats: %s", name, strings.Join(names, ", "))
+		return responseFormat{}, &parameterError{code: "invalid_parameter", parameter: "format", message: message}
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return formatByName(defaultFormat), nil
+	}
+	bestQuality := 0.0
+	var best *responseFormat
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality <= bestQuality {
+			continue
+		}
+		if format := formatByMediaType(mediaType); format != nil {
+			best, bestQuality = format, quality
+		}
+	}
+	if best == nil {
+		return responseFormat{}, errNotAcceptable
+	}
+	return *best, nil
+}
+
+var errNotAcceptable = errors.New("none of the accepted media types is available")
+
+func formatByName(name string) responseFormat {
+	for _, format := range responseFormats {
+		if format.name == name {
+			return format
+		}
+	}
+	panic("unknown response format " + name)
+}
+
+// formatByMediaType returns the format of mediaType, wildcards selecting the
+// first format of their type.
+func formatByMediaType(mediaType string) *responseFormat {
+	for i, format := range responseFormats {
+		for _, candidate := range format.mediaTypes {
+			mainType, _, _ := strings.Cut(candidate, "/")
+			if mediaType == candidate || mediaType == "*/*" || mediaType == mainType+"/*" {
+				return &responseFormats[i]
+			}
+		}
+	}
+	return nil
+}
+
+func encodeJSON(w io.Writer, page itemsPage) error {
+	return json.NewEncoder(w).Encode(page.Items)
+}
+
+func encodeNDJSON(w io.Writer, page itemsPage) error {
+	encoder := json.NewEncoder(w)
+	for _, item := range page.Items {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeCSV(w io.Writer, page itemsPage) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"order", "id", "title", "url", "score", "comments", "type", "sources"})
+	for _, item := range page.Items {
+		sources := make([]string, len(item.Sources))
+		for i, source := range item.Sources {
+			sources[i] = source.Source
+		}
+		writer.Write([]string{strconv.Itoa(item.Order), item.Id, item.Title, item.Url, strconv.Itoa(item.Score), strconv.Itoa(item.Comments), item.Type, strings.Join(sources, ";")})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Guid        rssGuid `xml:"guid"`
+	Description string  `xml:"description"`
+	Category    string  `xml:"category,omitempty"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func encodeRSS(w io.Writer, page itemsPage) error {
+	channel := rssChannel{Title: page.Title, Link: page.Link, Description: "Ranked " + page.Title, Items: make([]rssItem, len(page.Items))}
+	if !page.Updated.IsZero() {
+		channel.LastBuildDate = page.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for i, item := range page.Items {
+		channel.Items[i] = rssItem{Title: item.Title, Link: item.Url, Guid: rssGuid{IsPermaLink: false, Value: itemGuid(page, item)}, Description: itemSummary(item), Category: item.Type}
+	}
+	return encodeXML(w, rssDocument{Version: "2.0", Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	Id      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// encodeAtom renders page as an Atom feed authored by the service. Pages
+// without fetch time are dated at the Unix epoch, so that their ETag only
+// changes with their items.
+func encodeAtom(w io.Writer, page itemsPage) error {
+	updated := page.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	feed := atomFeed{Title: page.Title, Id: page.Link, Updated: updated.UTC().Format(time.RFC3339), Author: atomPerson{Name: serviceName},
+		Link: atomLink{Rel: "self", Href: page.Link}, Entries: make([]atomEntry, len(page.Items))}
+	for i, item := range page.Items {
+		feed.Entries[i] = atomEntry{Title: item.Title, Id: itemGuid(page, item), Updated: feed.Updated, Link: atomLink{Href: item.Url}, Summary: itemSummary(item)}
+	}
+	return encodeXML(w, feed)
+}
+
+func encodeXML(w io.Writer, document any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// itemGuid identifies an item in feeds by its link, or by its id for items
+// without one.
+func itemGuid(page itemsPage, item data.ScraperResponse) string {
+	if item.Url != "" {
+		return item.Url
+	}
+	return page.Link + "#" + item.Id
+}
+
+func itemSummary(item data.ScraperResponse) string {
+	return fmt.Sprintf("Score %d, %d comments", item.Score, item.Comments)
+}
+
+var itemsPageTemplate = template.Must(template.New("items").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ol>
+{{- range .Items}}
+<li value="{{.Order}}"><a href="{{.Url}}">{{.Title}}</a> ({{.Score}} points, {{.Comments}} comments{{range .Sources}}, {{.Source}}{{end}})</li>
+{{- end}}
+</ol>
+{{- if not .Updated.IsZero}}
+<p>Updated {{.Updated.UTC.Format "2006-01-02 15:04:05 MST"}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+func encodeHTML(w io.Writer, page itemsPage) error {
+	return itemsPageTemplate.Execute(w, page)
+}
+
+// encodeItems renders page in format, returning the whole body so that its
+// validators can be computed before writing it.
+func encodeItems(format responseFormat, page itemsPage) ([]byte, error) {
+	var body bytes.Buffer
+	if err := format.encode(&body, page); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// requestUrl rebuilds the absolute URL of r, used as link of the feeds.
+func requestUrl(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// pageTitle names the items of the sources of a response, for the titles
+// of feeds and HTML pages.
+func pageTitle(sourcesStatus []data.SourceStatus) string {
+	names := make([]string, len(sourcesStatus))
+	for i, status := range sourcesStatus {
+		names[i] = status.Source
+	}
+	return strings.Join(names, ", ") + " items"
+}
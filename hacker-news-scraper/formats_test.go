@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		accept  string
+		want    string
+		wantErr bool
+	}{
+		{name: "No preference", target: "/items", want: "json"},
+		{name: "Format parameter", target: "/items?format=csv", accept: "application/json", want: "csv"},
+		{name: "Unknown format parameter", target: "/items?format=xml", wantErr: true},
+		{name: "Accept media type", target: "/items", accept: "application/atom+xml", want: "atom"},
+		{name: "Accept preferred media type", target: "/items", accept: "text/html;q=0.5, application/rss+xml;q=0.9, */*;q=0.1", want: "rss"},
+		{name: "Browser Accept", target: "/items", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: "html"},
+		{name: "Accept wildcard", target: "/items", accept: "*/*", want: "json"},
+		{name: "Accept type wildcard", target: "/items", accept: "text/*", want: "csv"},
+		{name: "Refused media type", target: "/items", accept: "application/json;q=0, text/csv", want: "csv"},
+		{name: "Not acceptable", target: "/items", accept: "image/png", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			got, err := negotiateFormat(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("negotiateFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.name != tt.want {
+				t.Errorf("negotiateFormat() got = %q, want %q", got.name, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetrieveItemsFormats(t *testing.T) {
+	fetchedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	fetcher := &snapshotFetcher{items: []data.Item{
+		{Id: 1, Title: "Go 1.23 <released>", Url: "https://go.dev/blog/go1.23", Score: 300, Descendants: 120, Type: "story"},
+		{Id: 2, Title: "Ask HN: Projects, again", Url: "https://news.ycombinator.com/item?id=2", Score: 30, Descendants: 80, Type: "story"},
+	}, fetchedAt: fetchedAt}
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": fetcher})
+	handler := BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"hn"}})
+
+	tests := []struct {
+		name            string
+		target          string
+		accept          string
+		wantStatus      int
+		wantContentType string
+		check           func(t *testing.T, body []byte)
+	}{
+		{name: "JSON", target: "/items", wantStatus: http.StatusOK, wantContentType: "application/json", check: func(t *testing.T, body []byte) {
+			var items []data.ScraperResponse
+			if err := json.Unmarshal(body, &items); err != nil || len(items) != 2 {
+				t.Errorf("got %d JSON items (%v), want 2", len(items), err)
+			}
+		}},
+		{name: "NDJSON", target: "/items?format=ndjson", wantStatus: http.StatusOK, wantContentType: "application/x-ndjson", check: func(t *testing.T, body []byte) {
+			lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+			var item data.ScraperResponse
+			if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &item) != nil || item.Id != "2" {
+				t.Errorf("got NDJSON %q, want one item per line", body)
+			}
+		}},
+		{name: "CSV", target: "/items", accept: "text/csv", wantStatus: http.StatusOK, wantContentType: "text/csv; charset=utf-8", check: func(t *testing.T, body []byte) {
+			records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+			if err != nil || len(records) != 3 || records[2][2] != "Ask HN: Projects, again" || records[1][7] != "hn" {
+				t.Errorf("got CSV records %q (%v), want a header and 2 items", records, err)
+			}
+		}},
+		{name: "RSS", target: "/items?format=rss", wantStatus: http.StatusOK, wantContentType: "application/rss+xml; charset=utf-8", check: func(t *testing.T, body []byte) {
+			var rss rssDocument
+			if err := xml.Unmarshal(body, &rss); err != nil || len(rss.Channel.Items) != 2 || rss.Channel.Items[0].Title != "Go 1.23 <released>" ||
+				rss.Channel.Link != "http://example.com/items?format=rss" || rss.Channel.LastBuildDate != "Sat, 01 Jun 2024 12:00:00 +0000" {
+				t.Errorf("got RSS %+v (%v), want the ranked items", rss.Channel, err)
+			}
+		}},
+		{name: "Atom", target: "/items", accept: "application/atom+xml", wantStatus: http.StatusOK, wantContentType: "application/atom+xml; charset=utf-8", check: func(t *testing.T, body []byte) {
+			var feed atomFeed
+			if err := xml.Unmarshal(body, &feed); err != nil || len(feed.Entries) != 2 || feed.Entries[0].Link.Href != "https://go.dev/blog/go1.23" || feed.Updated != "2024-06-01T12:00:00Z" ||
+				feed.Author.Name != serviceName {
+				t.Errorf("got Atom feed %+v (%v), want the ranked items", feed, err)
+			}
+		}},
+		{name: "HTML", target: "/items", accept: "text/html", wantStatus: http.StatusOK, wantContentType: "text/html; charset=utf-8", check: func(t *testing.T, body []byte) {
+			if !bytes.Contains(body, []byte(`<a href="https://go.dev/blog/go1.23">Go 1.23 &lt;released&gt;</a>`)) {
+				t.Errorf("got HTML %s, want escaped item links", body)
+			}
+		}},
+		{name: "Unknown format", target: "/items?format=xml", wantStatus: http.StatusBadRequest, wantContentType: "application/json"},
+		{name: "Not acceptable", target: "/items", accept: "image/png", wantStatus: http.StatusNotAcceptable, wantContentType: "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantStatus)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type header = %q, want %q", got, tt.wantContentType)
+			}
+			if tt.check != nil {
+				tt.check(t, rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestEncodeAtomWithoutFetchTime(t *testing.T) {
+	page := itemsPage{Title: "hn items", Link: "http://example.com/items", Items: []data.ScraperResponse{{Order: 1, Id: "1", Title: "First", Url: "https://example.com/1"}}}
+	var first, second bytes.Buffer
+	if err := encodeAtom(&first, page); err != nil {
+		t.Fatalf("encodeAtom() error = %v", err)
+	}
+	encodeAtom(&second, page)
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("encodeAtom() rendered the same page differently:\n%s\n%s", first.Bytes(), second.Bytes())
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(first.Bytes(), &feed); err != nil || feed.Updated != "1970-01-01T00:00:00Z" || feed.Entries[0].Updated != feed.Updated {
+		t.Errorf("got Atom feed %+v (%v), want it dated at the Unix epoch", feed, err)
+	}
+}
@@ -0,0 +1,583 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/config"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/store"
+	"github.com/gorilla/mux"
+)
+
+const maxReturnItems = 30
+const maxLimit = config.MaxLimit
+
+// maxItemsWindow is the number of ranked items every page is sliced from, so
+// that successive pages continue the same ranking. Pages past it cannot be
+// requested.
+const maxItemsWindow = 5 * maxLimit
+const itemsMaxAge = 10 * time.Second
+const cursorPrefix = "offset:"
+const defaultCommentsDepth = 5
+const maxCommentsDepth = 20
+const defaultMaxComments = 200
+const maxCommentsLimit = 1000
+
+// itemsQuery holds the validated parameters of an items request.
+type itemsQuery struct {
+	connectors []services.SourceConnectors
+	ranker     services.Ranker
+	filter     services.ItemFilter
+	limit      int
+	offset     int
+}
+
+// itemsDefaults holds the values of the items parameters absent from the
+// requests of a route. Zero values stand for the built-in defaults.
+type itemsDefaults struct {
+	sources []string
+	ranking string
+	limit   int
+}
+
+// parameterError describes an invalid request parameter, reported to clients
+// as a structured 400 response.
+type parameterError struct {
+	code      string
+	parameter string
+	message   string
+}
+
+func (e *parameterError) Error() string { return e.message }
+
+// BuildItemsRetrieverHandler serves the ranked items of the registry sources
+// selected through the sources parameter, or of the default sources when
+// absent.
+func BuildItemsRetrieverHandler(registry *services.SourceRegistry, defaults itemsDefaults) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseItemsQuery(r, registry, defaults)
+		if err != nil {
+			writeParameterError(w, err)
+			return
+		}
+		format, err := negotiateFormat(r)
+		if errors.Is(err, errNotAcceptable) {
+			writeJSONError(w, http.StatusNotAcceptable, data.ErrorDetail{Code: "not_acceptable", Message: err.Error()})
+			return
+		} else if err != nil {
+			writeParameterError(w, err)
+			return
+		}
+		aggregator := services.Aggregator{Connectors: query.connectors, PartialResults: true, Ranker: query.ranker, Deduplicate: true, Filter: query.filter}
+		items, sourcesStatus, err := aggregator.GetItemsWithStatus(r.Context(), maxItemsWindow)
+		if r.Context().Err() != nil {
+			slog.InfoContext(r.Context(), "Request abandoned by client", "error", r.Context().Err())
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get items", "error", err)
+			http.Error(w, "Error obtaining required data", http.StatusInternalServerError)
+			return
+		}
+		next := query.offset + query.limit
+		hasNext := len(items) > next && next <= maxItemsWindow-query.limit
+		items = items[min(query.offset, len(items)):min(next, len(items))]
+
+		response := make([]data.ScraperResponse, len(items))
+		for i, item := range items {
+			num := query.offset + i + 1
+			slog.DebugContext(r.Context(), "Ranked item", "order", num, "id", services.ItemId(item), "title", item.Title,
+				"title_length", len(item.Title), "comments", item.Descendants, "score", item.Score)
+			response[i] = scraperResponse(item, num)
+		}
+		updated := lastModified(sourcesStatus)
+		body, err := encodeItems(format, itemsPage{Title: pageTitle(sourcesStatus), Link: requestUrl(r), Updated: updated, Items: response})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to build response", "error", err)
+			http.Error(w, "Error building service response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", format.contentType)
+		w.Header().Add("Vary", "Accept")
+		setSourcesStatusHeaders(w.Header(), sourcesStatus)
+		if hasNext {
+			w.Header().Set("X-Next-Cursor", encodeCursor(next))
+		}
+		if setValidators(w.Header(), body, updated); notModified(r, w.Header()) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(body); err != nil {
+			slog.WarnContext(r.Context(), "Failed to write response", "error", err)
+		}
+	}
+}
+
+func scraperResponse(item data.Item, order int) data.ScraperResponse {
+	return data.ScraperResponse{Order: order, Id: services.ItemId(item), Title: item.Title, Url: item.Url, Comments: item.Descendants, Score: item.Score,
+		Sources: item.Sources, Type: item.Type, Text: item.Text, PollOptions: item.PollOptions}
+}
+
+// lastModified returns the fetch time of the newest items served, zero when
+// no source reports it.
+func lastModified(sourcesStatus []data.SourceStatus) time.Time {
+	var lastModified time.Time
+	for _, status := range sourcesStatus {
+		if status.FetchedAt != nil && status.FetchedAt.After(lastModified) {
+			lastModified = *status.FetchedAt
+		}
+	}
+	return lastModified
+}
+
+// setValidators sets the ETag of body and the Last-Modified time of the
+// newest items served, letting clients revalidate their copy. Degraded
+// responses are not cached.
+func setValidators(header http.Header, body []byte, lastModified time.Time) {
+	hash := sha256.Sum256(body)
+	header.Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if header.Get("X-Partial-Results") != "" {
+		header.Set("Cache-Control", "no-cache")
+	} else {
+		header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", itemsMaxAge/time.Second))
+	}
+}
+
+// notModified evaluates the conditional headers of r against the validators
+// of the response, If-None-Match taking precedence over If-Modified-Since.
+func notModified(r *http.Request, header http.Header) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := header.Get("ETag")
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && !lastModified.After(ifModifiedSince)
+}
+
+// BuildHackerNewsListHandler serves the Hacker News list chosen through the
+// list route variable or query parameter, such as "new" or "ask", the
+// lists being registered as sources keyed by listSources.
+func BuildHackerNewsListHandler(registry *services.SourceRegistry, listSources map[string]string, defaultList string, defaults itemsDefaults) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := mux.Vars(r)["list"]
+		if list == "" {
+			list = r.URL.Query().Get("list")
+		}
+		if list == "" {
+			list = defaultList
+		}
+		sourceKey, ok := listSources[list]
+		if !ok {
+			lists := make([]string, 0, len(listSources))
+			for name := range listSources {
+				lists = append(lists, name)
+			}
+			slices.Sort(lists)
+			message := fmt.Sprintf("unknown list %q, available lists: %s", list, strings.Join(lists, ", "))
+			writeParameterError(w, &parameterError{code: "invalid_parameter", parameter: "list", message: message})
+			return
+		}
+		defaults.sources = []string{sourceKey}
+		BuildItemsRetrieverHandler(registry, defaults)(w, r)
+	}
+}
+
+// BuildCommentsHandler serves the comment thread of the story id of the
+// source route variable, for the registry sources supporting comments.
+func BuildCommentsHandler(registry *services.SourceRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		source, ok := registry.Get(vars["source"])
+		if !ok {
+			writeUnknownSourceError(w, registry, vars["source"])
+			return
+		}
+		comments, ok := services.CommentsOf(source.Connector)
+		if !ok {
+			message := fmt.Sprintf("source %q does not provide comments", vars["source"])
+			writeParameterError(w, &parameterError{code: "unsupported_source", parameter: "source", message: message})
+			return
+		}
+		options, err := parseCommentOptions(r)
+		if err != nil {
+			writeParameterError(w, err)
+			return
+		}
+
+		ctx := r.Context()
+		if source.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, source.Timeout)
+			defer cancel()
+		}
+		thread, err := comments.GetComments(ctx, vars["id"], options)
+		if r.Context().Err() != nil {
+			slog.InfoContext(r.Context(), "Request abandoned by client", "error", r.Context().Err())
+			return
+		}
+		if errors.Is(err, services.ErrStoryNotFound) {
+			message := fmt.Sprintf("story %q not found in %s", vars["id"], source.SourceName)
+			writeJSONError(w, http.StatusNotFound, data.ErrorDetail{Code: "story_not_found", Parameter: "id", Message: message})
+			return
+		}
+		if errors.Is(err, services.ErrCircuitOpen) {
+			message := fmt.Sprintf("source %q is unavailable, try again later", vars["source"])
+			writeJSONError(w, http.StatusServiceUnavailable, data.ErrorDetail{Code: "source_unavailable", Parameter: "source", Message: message})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get comments", "source", vars["source"], "story_id", vars["id"], "error", err)
+			http.Error(w, "Error obtaining required data", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(thread); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to build response", "error", err)
+		}
+	}
+}
+
+// BuildItemHistoryHandler serves the snapshots of an item recorded in
+// itemStore between the from and to parameters, by default its whole history.
+func BuildItemHistoryHandler(registry *services.SourceRegistry, itemStore store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		if _, ok := registry.Get(vars["source"]); !ok {
+			writeUnknownSourceError(w, registry, vars["source"])
+			return
+		}
+		from, err := parseTimeParameter(r, "from", time.Time{})
+		if err != nil {
+			writeParameterError(w, err)
+			return
+		}
+		to, err := parseTimeParameter(r, "to", time.Now())
+		if err != nil {
+			writeParameterError(w, err)
+			return
+		}
+
+		snapshots, err := itemStore.History(vars["source"], vars["id"], from, to)
+		if errors.Is(err, store.ErrNotFound) {
+			message := fmt.Sprintf("no history recorded for item %q of %s", vars["id"], vars["source"])
+			writeJSONError(w, http.StatusNotFound, data.ErrorDetail{Code: "item_not_found", Parameter: "id", Message: message})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to read item history", "source", vars["source"], "id", vars["id"], "error", err)
+			http.Error(w, "Error obtaining required data", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(data.ItemHistory{Source: vars["source"], Id: vars["id"], Snapshots: snapshots}); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to build response", "error", err)
+		}
+	}
+}
+
+// BuildFrontPageHandler serves the items of a source as recorded by its last
+// fetch before the at parameter, by default its latest recorded fetch.
+func BuildFrontPageHandler(registry *services.SourceRegistry, itemStore store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		source := mux.Vars(r)["source"]
+		if _, ok := registry.Get(source); !ok {
+			writeUnknownSourceError(w, registry, source)
+			return
+		}
+		at, err := parseTimeParameter(r, "at", time.Now())
+		if err != nil {
+			writeParameterError(w, err)
+			return
+		}
+
+		frontPage, err := itemStore.FrontPage(source, at)
+		if errors.Is(err, store.ErrNotFound) {
+			message := fmt.Sprintf("no front page of %s recorded before %s", source, at.Format(time.RFC3339))
+			writeJSONError(w, http.StatusNotFound, data.ErrorDetail{Code: "front_page_not_found", Parameter: "at", Message: message})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to read front page", "source", source, "error", err)
+			http.Error(w, "Error obtaining required data", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(frontPage); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to build response", "error", err)
+		}
+	}
+}
+
+// parseTimeParameter reads an RFC 3339 time parameter, such as
+// "2024-06-01T12:00:00Z", returning defaultTime when absent.
+func parseTimeParameter(r *http.Request, name string, defaultTime time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultTime, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return parsed, &parameterError{code: "invalid_parameter", parameter: name, message: name + " must be an RFC 3339 time such as 2024-06-01T12:00:00Z"}
+	}
+	return parsed, nil
+}
+
+func parseCommentOptions(r *http.Request) (services.CommentOptions, error) {
+	values := r.URL.Query()
+	options := services.CommentOptions{MaxDepth: defaultCommentsDepth, MaxComments: defaultMaxComments}
+	var err error
+	if depth := values.Get("max_depth"); depth != "" {
+		if options.MaxDepth, err = strconv.Atoi(depth); err != nil || options.MaxDepth < 1 || options.MaxDepth > maxCommentsDepth {
+			return options, &parameterError{code: "invalid_parameter", parameter: "max_depth", message: fmt.Sprintf("max_depth must be a number between 1 and %d", maxCommentsDepth)}
+		}
+	}
+	if maxComments := values.Get("max_comments"); maxComments != "" {
+		if options.MaxComments, err = strconv.Atoi(maxComments); err != nil || options.MaxComments < 1 || options.MaxComments > maxCommentsLimit {
+			return options, &parameterError{code: "invalid_parameter", parameter: "max_comments", message: fmt.Sprintf("max_comments must be a number between 1 and %d", maxCommentsLimit)}
+		}
+	}
+	return options, nil
+}
+
+func parseItemsQuery(r *http.Request, registry *services.SourceRegistry, defaults itemsDefaults) (itemsQuery, error) {
+	values := r.URL.Query()
+	query := itemsQuery{limit: defaults.limit}
+	if query.limit == 0 {
+		query.limit = maxReturnItems
+	}
+
+	sourceKeys := defaults.sources
+	if sourcesParam := values.Get("sources"); sourcesParam != "" {
+		sourceKeys = strings.Split(sourcesParam, ",")
+	}
+	connectors, err := registry.Lookup(sourceKeys)
+	var unknownSource services.UnknownSourceError
+	if errors.As(err, &unknownSource) {
+		message := fmt.Sprintf("%v, available sources: %s", err, strings.Join(registry.Keys(), ", "))
+		return query, &parameterError{code: "unknown_source", parameter: "sources", message: message}
+	} else if err != nil || len(connectors) == 0 {
+		return query, &parameterError{code: "invalid_parameter", parameter: "sources", message: "at least one source is required"}
+	}
+	query.connectors = connectors
+
+	ranking := values.Get("sort")
+	if ranking == "" {
+		ranking = defaults.ranking
+	}
+	if query.ranker, err = services.RankerByName(ranking); err != nil {
+		return query, &parameterError{code: "invalid_parameter", parameter: "sort", message: err.Error()}
+	}
+	if query.filter, err = parseItemFilter(r); err != nil {
+		return query, err
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if query.limit, err = strconv.Atoi(limit); err != nil || query.limit < 1 || query.limit > maxLimit {
+			return query, &parameterError{code: "invalid_parameter", parameter: "limit", message: fmt.Sprintf("limit must be a number between 1 and %d", maxLimit)}
+		}
+	}
+
+	offset, cursor := values.Get("offset"), values.Get("cursor")
+	switch {
+	case offset != "" && cursor != "":
+		return query, &parameterError{code: "invalid_parameter", parameter: "cursor", message: "offset and cursor cannot be combined"}
+	case offset != "":
+		if query.offset, err = strconv.Atoi(offset); err != nil || query.offset < 0 {
+			return query, &parameterError{code: "invalid_parameter", parameter: "offset", message: "offset must be a non negative number"}
+		}
+	case cursor != "":
+		if query.offset, err = decodeCursor(cursor); err != nil {
+			return query, &parameterError{code: "invalid_parameter", parameter: "cursor", message: "malformed cursor"}
+		}
+	}
+	if query.offset > maxItemsWindow-query.limit {
+		parameter := "offset"
+		if cursor != "" {
+			parameter = "cursor"
+		}
+		return query, &parameterError{code: "invalid_parameter", parameter: parameter, message: fmt.Sprintf("only the first %d items can be paged through", maxItemsWindow)}
+	}
+	return query, nil
+}
+
+// parseItemFilter reads the filters of the items: q, matching titles
+// containing it or, between slashes, the regular expression matching them;
+// domain and exclude_domain; by, type, min_score and min_comments; and the
+// since and until times. Several domains, authors or types are separated by
+// commas.
+func parseItemFilter(r *http.Request) (services.ItemFilter, error) {
+	values := r.URL.Query()
+	var filter services.ItemFilter
+	var err error
+	if q := values.Get("q"); len(q) > 2 && strings.HasPrefix(q, "/") && strings.HasSuffix(q, "/") {
+		if filter.Pattern, err = regexp.Compile("(?i)" + q[1:len(q)-1]); err != nil {
+			return filter, &parameterError{code: "invalid_parameter", parameter: "q", message: "invalid regular expression: " + err.Error()}
+		}
+	} else if q != "" {
+		filter.Keywords = []string{q}
+	}
+	filter.Domains = splitParameter(values.Get("domain"))
+	filter.ExcludedDomains = splitParameter(values.Get("exclude_domain"))
+	filter.Authors = splitParameter(values.Get("by"))
+	filter.Types = splitParameter(values.Get("type"))
+	minimums := []struct {
+		name   string
+		target *int
+	}{
+		{name: "min_score", target: &filter.MinScore},
+		{name: "min_comments", target: &filter.MinComments},
+	}
+	for _, minimum := range minimums {
+		if value := values.Get(minimum.name); value != "" {
+			if *minimum.target, err = strconv.Atoi(value); err != nil || *minimum.target < 0 {
+				return filter, &parameterError{code: "invalid_parameter", parameter: minimum.name, message: minimum.name + " must be a non negative number"}
+			}
+		}
+	}
+	if filter.Since, err = parseTimeParameter(r, "since", time.Time{}); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseTimeParameter(r, "until", time.Time{}); err != nil {
+		return filter, err
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Until.Before(filter.Since) {
+		return filter, &parameterError{code: "invalid_parameter", parameter: "until", message: "until must not be before since"}
+	}
+	return filter, nil
+}
+
+// splitParameter returns the non empty comma separated values of value.
+func splitParameter(value string) []string {
+	var values []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			values = append(values, field)
+		}
+	}
+	return values
+}
+
+// encodeCursor builds the opaque cursor pointing to the page starting at offset.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), cursorPrefix) {
+		return 0, errors.New("malformed cursor")
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(decoded), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, errors.New("malformed cursor")
+	}
+	return offset, nil
+}
+
+func writeParameterError(w http.ResponseWriter, err error) {
+	var paramErr *parameterError
+	if !errors.As(err, &paramErr) {
+		paramErr = &parameterError{code: "invalid_request", message: err.Error()}
+	}
+	writeJSONError(w, http.StatusBadRequest, data.ErrorDetail{Code: paramErr.code, Parameter: paramErr.parameter, Message: paramErr.message})
+}
+
+func writeUnknownSourceError(w http.ResponseWriter, registry *services.SourceRegistry, key string) {
+	message := fmt.Sprintf("unknown source %q, available sources: %s", key, strings.Join(registry.Keys(), ", "))
+	writeJSONError(w, http.StatusNotFound, data.ErrorDetail{Code: "unknown_source", Parameter: "source", Message: message})
+}
+
+func writeJSONError(w http.ResponseWriter, status int, detail data.ErrorDetail) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data.ErrorResponse{Error: detail}); err != nil {
+		slog.Error("Failed to build error response", "error", err)
+	}
+}
+
+// setSourcesStatusHeaders reports the outcome of every source through one
+// X-Source-Status header per source, flagging degraded responses with
+// X-Partial-Results. X-Data-Age gives the age in seconds of the oldest
+// items served, fetched earlier than the request by polled sources.
+func setSourcesStatusHeaders(header http.Header, sourcesStatus []data.SourceStatus) {
+	partial := false
+	var oldest time.Time
+	for _, status := range sourcesStatus {
+		value := fmt.Sprintf("%s; status=%s; latency_ms=%d", status.Source, status.State, status.LatencyMs)
+		if status.FetchedAt != nil {
+			value += fmt.Sprintf("; age=%d", dataAge(*status.FetchedAt))
+			if oldest.IsZero() || status.FetchedAt.Before(oldest) {
+				oldest = *status.FetchedAt
+			}
+		}
+		if status.Error != "" {
+			value += "; error=" + strconv.Quote(status.Error)
+		}
+		header.Add("X-Source-Status", value)
+		partial = partial || status.State != data.SourceOk
+	}
+	if partial {
+		header.Set("X-Partial-Results", "true")
+	}
+	if !oldest.IsZero() {
+		header.Set("X-Data-Age", strconv.Itoa(dataAge(oldest)))
+	}
+}
+
+func dataAge(fetchedAt time.Time) int {
+	return int(max(time.Since(fetchedAt), 0) / time.Second)
+}
+
+type breakerStatusResponse struct {
+	Source string `json:"source"`
+	services.BreakerStatus
+}
+
+func BuildBreakersStatusHandler(breakers map[string]*services.CircuitBreaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sourceNames := make([]string, 0, len(breakers))
+		for sourceName := range breakers {
+			sourceNames = append(sourceNames, sourceName)
+		}
+		slices.Sort(sourceNames)
+		response := make([]breakerStatusResponse, len(sourceNames))
+		for i, sourceName := range sourceNames {
+			response[i] = breakerStatusResponse{Source: sourceName, BreakerStatus: breakers[sourceName].Status()}
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to build response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+)
+
+// BuildHealthHandler answers while the process is alive.
+func BuildHealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, r, http.StatusOK, data.HealthResponse{Status: "ok"})
+	}
+}
+
+// BuildReadinessHandler answers whether the items of the registry sources can
+// be served. Sources fetched on request, with a nil poller, are always
+// ready. Polled sources are ready once every source has been polled and any
+// of them has fresh items, so a single failing source does not take the
+// service down.
+func BuildReadinessHandler(registry *services.SourceRegistry, poller *services.Poller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if poller == nil {
+			writeHealth(w, r, http.StatusOK, data.HealthResponse{Status: "ready"})
+			return
+		}
+		keys := registry.Keys()
+		response := data.HealthResponse{Status: "ready", Sources: make([]data.SourceReadiness, len(keys))}
+		allPolled, anyFresh := true, false
+		for i, key := range keys {
+			snapshot, polled := poller.Snapshot(key)
+			readiness := data.SourceReadiness{Source: key, Polled: polled, Fresh: poller.Fresh(key)}
+			if !snapshot.FetchedAt.IsZero() {
+				readiness.FetchedAt = &snapshot.FetchedAt
+			}
+			allPolled, anyFresh = allPolled && readiness.Polled, anyFresh || readiness.Fresh
+			response.Sources[i] = readiness
+		}
+		status := http.StatusOK
+		if !allPolled || !anyFresh {
+			response.Status, status = "not_ready", http.StatusServiceUnavailable
+		}
+		writeHealth(w, r, status, response)
+	}
+}
+
+// BuildSourcesStatusHandler reports the outcome of the last fetches of every
+// registry source and the state of its circuit breaker, without fetching
+// the sources.
+func BuildSourcesStatusHandler(registry *services.SourceRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys := registry.Keys()
+		response := make([]data.SourceHealth, len(keys))
+		for i, key := range keys {
+			source, _ := registry.Get(key)
+			health := data.SourceHealth{Source: key, Name: source.SourceName}
+			if instrumented, ok := services.RetrieverAs[*services.InstrumentedRetriever](source.Connector); ok {
+				lastFetch := instrumented.LastFetch()
+				if !lastFetch.LastSuccess.IsZero() {
+					health.LastSuccess = &lastFetch.LastSuccess
+				}
+				if !lastFetch.LastFailure.IsZero() {
+					health.LastFailure = &lastFetch.LastFailure
+				}
+				health.LastError, health.LatencyMs, health.Items = lastFetch.LastError, lastFetch.Latency.Milliseconds(), lastFetch.Items
+			}
+			if breaker, ok := services.RetrieverAs[*services.CircuitBreaker](source.Connector); ok {
+				health.BreakerState = string(breaker.Status().State)
+			}
+			response[i] = health
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to build response", "error", err)
+		}
+	}
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, status int, response data.HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to build response", "error", err)
+	}
+}
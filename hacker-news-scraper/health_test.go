@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestHealth(t *testing.T) {
+	rr := httptest.NewRecorder()
+	BuildHealthHandler().ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	var response data.HealthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if rr.Code != http.StatusOK || response.Status != "ok" {
+		t.Errorf("handler returned %v %q, want %v %q", rr.Code, response.Status, http.StatusOK, "ok")
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hnFetcher := mock_services.NewMockRetriever(ctrl)
+	lobstersFetcher := mock_services.NewMockRetriever(ctrl)
+	poller := services.NewPoller()
+	hn := poller.Add("hn", services.SourceConnectors{SourceName: "hn", Connector: hnFetcher}, time.Minute, 10)
+	lobsters := poller.Add("lobsters", services.SourceConnectors{SourceName: "lobsters", Connector: lobstersFetcher}, time.Minute, 10)
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": hn.Connector, "lobsters": lobsters.Connector})
+	handler := BuildReadinessHandler(registry, poller)
+
+	steps := []struct {
+		name       string
+		poll       func()
+		wantStatus int
+		wantFresh  []bool
+	}{
+		{name: "Sources not polled", wantStatus: http.StatusServiceUnavailable, wantFresh: []bool{false, false}},
+		{name: "Source polled", poll: func() {
+			hnFetcher.EXPECT().GetItems(gomock.Any(), 10).Return([]data.Item{{Id: 1, Title: "First"}}, nil)
+			hn.Connector.(services.SnapshotRetriever).GetSnapshot(context.Background(), 10)
+		}, wantStatus: http.StatusServiceUnavailable, wantFresh: []bool{true, false}},
+		{name: "Every source polled", poll: func() {
+			lobstersFetcher.EXPECT().GetItems(gomock.Any(), 10).Return(nil, errors.New("source down"))
+			lobsters.Connector.(services.SnapshotRetriever).GetSnapshot(context.Background(), 10)
+		}, wantStatus: http.StatusOK, wantFresh: []bool{true, false}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.poll != nil {
+				step.poll()
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+			if rr.Code != step.wantStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, step.wantStatus)
+			}
+			var response data.HealthResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+			if len(response.Sources) != len(step.wantFresh) {
+				t.Fatalf("handler returned %d sources, want %d", len(response.Sources), len(step.wantFresh))
+			}
+			for i, source := range response.Sources {
+				if source.Fresh != step.wantFresh[i] || (source.FetchedAt != nil) != step.wantFresh[i] {
+					t.Errorf("source %s fresh = %v fetched at %v, want fresh %v", source.Source, source.Fresh, source.FetchedAt, step.wantFresh[i])
+				}
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	BuildReadinessHandler(registry, nil).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler without poller returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestSourcesStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hnFetcher := mock_services.NewMockRetriever(ctrl)
+	lobstersFetcher := mock_services.NewMockRetriever(ctrl)
+	hn := services.NewCircuitBreaker("hn", services.NewInstrumentedRetriever("hn", hnFetcher), services.BreakerConfig{FailureThreshold: 1, CoolDown: time.Minute, HalfOpenSuccesses: 1})
+	lobsters := services.NewCircuitBreaker("lobsters", services.NewInstrumentedRetriever("lobsters", lobstersFetcher), services.DefaultBreakerConfig)
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": hn, "lobsters": lobsters, "plain": mock_services.NewMockRetriever(ctrl)})
+
+	hnFetcher.EXPECT().GetItems(gomock.Any(), 10).Return(nil, errors.New("source down"))
+	hn.GetItems(context.Background(), 10)
+	lobstersFetcher.EXPECT().GetItems(gomock.Any(), 10).Return([]data.Item{{Id: 1, Title: "First"}, {Id: 2, Title: "Second"}}, nil)
+	lobsters.GetItems(context.Background(), 10)
+
+	// The mocks fail the test on any further fetch.
+	rr := httptest.NewRecorder()
+	BuildSourcesStatusHandler(registry).ServeHTTP(rr, httptest.NewRequest("GET", "/status/sources", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response []data.SourceHealth
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(response) != 3 {
+		t.Fatalf("handler returned %d sources, want 3", len(response))
+	}
+	if got := response[0]; got.Source != "hn" || got.LastSuccess != nil || got.LastFailure == nil || got.LastError != "source down" || got.BreakerState != "open" {
+		t.Errorf("hn status = %+v, want an open breaker after a failure", got)
+	}
+	if got := response[1]; got.Source != "lobsters" || got.LastSuccess == nil || got.LastFailure != nil || got.Items != 2 || got.BreakerState != "closed" {
+		t.Errorf("lobsters status = %+v, want a closed breaker after a success of 2 items", got)
+	}
+	if got := response[2]; got.Source != "plain" || got.LastSuccess != nil || got.BreakerState != "" {
+		t.Errorf("plain status = %+v, want no fetch nor breaker", got)
+	}
+}
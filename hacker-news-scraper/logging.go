@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/logging"
+)
+
+const requestIdHeader = "X-Request-ID"
+
+// validRequestId matches the request IDs accepted from clients and proxies.
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// setupLogging makes the structured logger set by LOG_LEVEL and LOG_FORMAT
+// the default one, the log package included.
+func setupLogging() {
+	logger, err := logging.NewLogger(os.Stderr, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		log.Fatalf("could not set up logging: %v", err)
+	}
+	slog.SetDefault(logger)
+}
+
+// requestIdMiddleware identifies every request by the X-Request-ID header
+// sent by the client, or a new random ID, echoed in the response and carried
+// by the request context to the logs of its handling.
+func requestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(requestIdHeader)
+		if !validRequestId.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		w.Header().Set(requestIdHeader, requestId)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestId(r.Context(), requestId)))
+	})
+}
+
+func newRequestId() string {
+	var id [16]byte
+	// crypto/rand does not fail on the supported platforms.
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,73 @@
+// Package logging configures the structured logs of the scraper, adding to
+// every record the request ID and trace of its context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIdKey struct{}
+
+// WithRequestId returns a copy of ctx carrying the ID of the request it
+// serves, logged along with every record of the request.
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestId returns the request ID carried by ctx, if any.
+func RequestId(ctx context.Context) (string, bool) {
+	requestId, ok := ctx.Value(requestIdKey{}).(string)
+	return requestId, ok
+}
+
+// ContextHandler is a slog.Handler adding to the records logged with a
+// context its request ID and the trace and span IDs of its span.
+type ContextHandler struct {
+	slog.Handler
+}
+
+func (h ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if requestId, ok := RequestId(ctx); ok {
+			record.AddAttrs(slog.String("request_id", requestId))
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return ContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h ContextHandler) WithGroup(name string) slog.Handler {
+	return ContextHandler{h.Handler.WithGroup(name)}
+}
+
+// NewLogger builds a logger writing to w the records of level or above, as
+// "text" or "json" as format states. Empty values select info and text.
+func NewLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var logLevel slog.Level
+	if level != "" {
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", level)
+		}
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(ContextHandler{slog.NewTextHandler(w, options)}), nil
+	case "json":
+		return slog.New(ContextHandler{slog.NewJSONHandler(w, options)}), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+}
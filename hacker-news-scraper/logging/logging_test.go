@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      string
+		format     string
+		wantErr    bool
+		wantDebug  bool
+		wantPrefix string
+	}{
+		{name: "Defaults", wantPrefix: "time="},
+		{name: "Debug level", level: "debug", wantDebug: true, wantPrefix: "time="},
+		{name: "JSON format", level: "WARN", format: "json", wantPrefix: "{"},
+		{name: "Unknown level", level: "verbose", wantErr: true},
+		{name: "Unknown format", format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			logger, err := NewLogger(&output, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLogger() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			logger.Debug("Debug record")
+			logger.Error("Error record")
+			if got := strings.Contains(output.String(), "Debug record"); got != tt.wantDebug {
+				t.Errorf("debug record logged = %v, want %v", got, tt.wantDebug)
+			}
+			if !strings.HasPrefix(output.String(), tt.wantPrefix) || !strings.Contains(output.String(), "Error record") {
+				t.Errorf("logged %q, want records starting with %q", output.String(), tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestContextHandler(t *testing.T) {
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceId, SpanID: spanId})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]string
+	}{
+		{name: "Without request", ctx: context.Background(), want: map[string]string{}},
+		{name: "Request", ctx: WithRequestId(context.Background(), "abc123"), want: map[string]string{"request_id": "abc123"}},
+		{name: "Request and span", ctx: trace.ContextWithSpanContext(WithRequestId(context.Background(), "abc123"), spanContext),
+			want: map[string]string{"request_id": "abc123", "trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "span_id": "00f067aa0ba902b7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			logger, _ := NewLogger(&output, "info", "json")
+			logger.With("source", "hn").InfoContext(tt.ctx, "Fetching")
+			var record map[string]any
+			if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+				t.Fatalf("could not unmarshal record %q: %v", output.String(), err)
+			}
+			if record["source"] != "hn" {
+				t.Errorf("record %v lost its attributes", record)
+			}
+			for _, key := range []string{"request_id", "trace_id", "span_id"} {
+				got, _ := record[key].(string)
+				if got != tt.want[key] {
+					t.Errorf("record %s = %q, want %q", key, got, tt.want[key])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/logging"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	"github.com/gorilla/mux"
+)
+
+func TestRequestIdMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestId string
+		wantSame  bool
+	}{
+		{name: "New request ID", requestId: ""},
+		{name: "Client request ID", requestId: "req-42.a:b", wantSame: true},
+		{name: "Invalid request ID", requestId: "bad id\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var contextId string
+			handler := requestIdMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				contextId, _ = logging.RequestId(r.Context())
+			}))
+			req := httptest.NewRequest("GET", "/items", nil)
+			if tt.requestId != "" {
+				req.Header.Set(requestIdHeader, tt.requestId)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(requestIdHeader)
+			if got == "" || got != contextId {
+				t.Fatalf("response request ID %q, context request ID %q, want the same one", got, contextId)
+			}
+			if (got == tt.requestId) != tt.wantSame {
+				t.Errorf("request ID = %q, want the client one %v", got, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestRequestIdLogs(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := logging.NewLogger(&output, "debug", "json")
+	if err != nil {
+		t.Fatalf("could not build logger: %v", err)
+	}
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(defaultLogger)
+
+	fetcher := &rankingsFetcher{rankings: [][]data.Item{{{Id: 1, Title: "First", Score: 10}}}, done: make(chan struct{})}
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": fetcher})
+	r := mux.NewRouter()
+	r.HandleFunc("/items", BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"hn"}})).Methods("GET")
+	r.Use(requestIdMiddleware)
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set(requestIdHeader, "req-42")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	messages := make(map[string]string)
+	decoder := json.NewDecoder(&output)
+	for decoder.More() {
+		var record struct {
+			Level     string `json:"level"`
+			Msg       string `json:"msg"`
+			RequestId string `json:"request_id"`
+		}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("could not decode log record: %v", err)
+		}
+		if record.RequestId != "req-42" {
+			t.Errorf("record %q logged with request ID %q, want %q", record.Msg, record.RequestId, "req-42")
+		}
+		messages[record.Msg] = record.Level
+	}
+	wantMessages := map[string]string{"Fetching results from sources": "INFO", "Ranked item": "DEBUG"}
+	for message, level := range wantMessages {
+		if messages[message] != level {
+			t.Errorf("record %q logged at level %q, want %q", message, messages[message], level)
+		}
+	}
+}
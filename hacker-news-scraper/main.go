@@ -1,76 +1,67 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
-	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
-	"github.com/gorilla/mux"
+	"context"
 	"log"
+	"log/slog"
 	"net/http"
-	"strconv"
-)
-
-const hackerNewsName = "Hacker News"
-const hnApiUrl = "https://hacker-news.firebaseio.com/v0"
-const lobstersName = "Lobsters"
-const lobstersWebUrl = "https://lobste.rs/"
-const maxReturnItems = 30
+	"os"
+	"time"
 
-func BuildItemsRetrieverHandler(sourcesRetrievers map[string]services.Retriever) http.HandlerFunc {
-	return func(w http.ResponseWriter, _ *http.Request) {
-		connectors := make([]services.SourceConnectors, 0)
-		for sourceName, retriever := range sourcesRetrievers {
-			connectors = append(connectors, services.SourceConnectors{SourceName: sourceName, Connector: retriever})
-		}
-		aggregator := services.Aggregator{Connectors: connectors}
-		items, err := aggregator.GetItems(maxReturnItems)
-		if err != nil {
-			log.Printf("Failed to get Connector: %v\n", err)
-			http.Error(w, "Error obtaining required data", http.StatusInternalServerError)
-			return
-		}
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/config"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/store"
+)
 
-		// Setting the default content-type header to JSON.
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		response := make([]data.ScraperResponse, len(items))
-		for i, item := range items {
-			title := item.Title
-			num := i + 1
-			comments := item.Descendants
-			id := int(item.Id)
-			score := item.Score
-			fmt.Printf("%d - Title(%d): %s (%d comments, score %d). Id: %d\n", num, len(title), title, comments, score, id)
-			response[i] = data.ScraperResponse{Order: num, Id: strconv.Itoa(id), Title: title, Url: item.Url, Comments: comments, Score: score}
-		}
-		err = json.NewEncoder(w).Encode(response)
-		if err != nil {
-			log.Printf("Failed to build response: %v", err)
-			http.Error(w, "Error building service response", http.StatusInternalServerError)
-			return
-		}
-	}
-}
+const tracingShutdownTimeout = 5 * time.Second
+const pruneInterval = time.Hour
 
 func main() {
-	r := mux.NewRouter()
+	setupLogging()
+	configPath := os.Getenv("CONFIG_FILE")
+	appConfig, err := config.Load(configPath, os.Getenv)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
 
-	var hackerNewsRetriever services.Retriever
-	hackerNewsRetriever = &services.APIConnector{Url: hnApiUrl, ItemsEndPoint: "topstories", ItemDataEndPoint: "item"}
-	hackerRankRetrieveHandler := BuildItemsRetrieverHandler(map[string]services.Retriever{hackerNewsName: hackerNewsRetriever})
-	r.HandleFunc("/hacker-news-items", hackerRankRetrieveHandler).Methods("GET")
+	shutdownTracing, err := setupTracing(context.Background(), os.Getenv("TRACES_EXPORTER"))
+	if err != nil {
+		log.Fatalf("could not set up tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush spans", "error", err)
+		}
+	}()
 
-	lobstersRetriever := &services.WebScrapperConnector{Url: lobstersWebUrl}
-	lobstersWebScrapeRetrieveHandler := BuildItemsRetrieverHandler(map[string]services.Retriever{lobstersName: lobstersRetriever})
-	r.HandleFunc("/lobsters-items", lobstersWebScrapeRetrieveHandler).Methods("GET")
+	itemStore, err := store.OpenBoltStore(appConfig.StorePath)
+	if err != nil {
+		log.Fatalf("could not open item store %s: %v", appConfig.StorePath, err)
+	}
+	defer itemStore.Close()
+	if appConfig.StoreRetention > 0 {
+		go store.Retain(context.Background(), itemStore, appConfig.StoreRetention, pruneInterval)
+	}
 
-	combinedRetrieverHandler := BuildItemsRetrieverHandler(map[string]services.Retriever{hackerNewsName: hackerNewsRetriever, lobstersName: lobstersRetriever})
-	r.HandleFunc("/combine-sources-items", combinedRetrieverHandler).Methods("GET")
-	http.Handle("/", r)
+	current, err := newApp(appConfig, itemStore, nil)
+	if err != nil {
+		log.Fatalf("could not build routes: %v", err)
+	}
+	handler := &appHandler{}
+	handler.replace(current)
+	go reloadOnHangup(configPath, handler, itemStore)
+	http.Handle("/", handler)
 
-	log.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	server := &http.Server{
+		Addr:              appConfig.Server.Address,
+		ReadHeaderTimeout: appConfig.Server.ReadHeaderTimeout,
+		ReadTimeout:       appConfig.Server.ReadTimeout,
+		WriteTimeout:      appConfig.Server.WriteTimeout,
+		IdleTimeout:       appConfig.Server.IdleTimeout,
+	}
+	slog.Info("Starting server", "address", server.Addr, "config", configPath)
+	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("could not start server: %v", err)
 	}
 }
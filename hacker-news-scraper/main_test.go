@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
 	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
 	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/store"
 	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRetrieveHackerNewsItems(t *testing.T) {
@@ -25,10 +36,11 @@ func TestRetrieveHackerNewsItems(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockFetcher.EXPECT().GetItems(maxReturnItems).Return(items, nil)
+	mockFetcher.EXPECT().GetItems(gomock.Any(), maxItemsWindow).Return(items, nil)
 
 	// Create the handler with the mock fetcher
-	handler := BuildItemsRetrieverHandler(map[string]services.Retriever{"test": mockFetcher})
+	registry := newTestRegistry(t, map[string]services.Retriever{"test": mockFetcher})
+	handler := BuildItemsRetrieverHandler(registry, itemsDefaults{sources: registry.Keys()})
 
 	req, err := http.NewRequest("GET", "/ids", nil)
 	if err != nil {
@@ -68,3 +80,584 @@ func TestRetrieveHackerNewsItems(t *testing.T) {
 	}
 
 }
+
+func TestRetrievePartialItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	healthyFetcher := mock_services.NewMockRetriever(ctrl)
+	healthyFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return([]data.Item{{Id: 1, Title: "Healthy source item", Score: 10}}, nil)
+	failingFetcher := mock_services.NewMockRetriever(ctrl)
+	failingFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(nil, errors.New("source down"))
+
+	registry := newTestRegistry(t, map[string]services.Retriever{"healthy": healthyFetcher, "failing": failingFetcher})
+	handler := BuildItemsRetrieverHandler(registry, itemsDefaults{sources: registry.Keys()})
+	req := httptest.NewRequest("GET", "/ids", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got := rr.Header().Get("X-Partial-Results"); got != "true" {
+		t.Errorf("X-Partial-Results header = %q, want %q", got, "true")
+	}
+	if got := len(rr.Header().Values("X-Source-Status")); got != 2 {
+		t.Errorf("got %d X-Source-Status headers, want 2", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Cache-Control header = %q, want %q", got, "no-cache")
+	}
+	var scrapedResult []data.ScraperResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &scrapedResult); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if len(scrapedResult) != 1 || scrapedResult[0].Title != "Healthy source item" {
+		t.Errorf("unexpected partial response %v", scrapedResult)
+	}
+}
+
+func TestRetrieveSortedItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	items := []data.Item{{Id: 1, Title: "Lower score", Score: 10, Descendants: 90}, {Id: 2, Title: "Higher score", Score: 200, Descendants: 3}}
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	mockFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(items, nil).AnyTimes()
+	registry := newTestRegistry(t, map[string]services.Retriever{"test": mockFetcher})
+	handler := BuildItemsRetrieverHandler(registry, itemsDefaults{sources: registry.Keys()})
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantIds    []string
+	}{
+		{name: "Sorted by score", target: "/ids?sort=score", wantStatus: http.StatusOK, wantIds: []string{"2", "1"}},
+		{name: "Sorted by comments", target: "/ids?sort=comments", wantStatus: http.StatusOK, wantIds: []string{"1", "2"}},
+		{name: "Unknown sort", target: "/ids?sort=random", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var scrapedResult []data.ScraperResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &scrapedResult); err != nil {
+				t.Fatalf("could not unmarshal response: %v", err)
+			}
+			gotIds := make([]string, len(scrapedResult))
+			for i, item := range scrapedResult {
+				gotIds[i] = item.Id
+			}
+			if !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("handler returned ids %v, want %v", gotIds, tt.wantIds)
+			}
+		})
+	}
+}
+
+func newTestRegistry(t *testing.T, retrievers map[string]services.Retriever) *services.SourceRegistry {
+	registry := services.NewSourceRegistry()
+	keys := make([]string, 0, len(retrievers))
+	for key := range retrievers {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if err := registry.Register(key, services.SourceConnectors{SourceName: key, Connector: retrievers[key]}); err != nil {
+			t.Fatalf("could not register source: %v", err)
+		}
+	}
+	return registry
+}
+
+func TestRetrieveItemsQueryParameters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hnItems := []data.Item{{Id: 1, Title: "HN first", Score: 50}, {Id: 2, Title: "HN second", Score: 40}, {Id: 3, Title: "HN third", Score: 30}}
+	lobstersItems := []data.Item{{Id: 11, Title: "Lobsters first", Score: 45}, {Id: 12, Title: "Lobsters second", Score: 35}}
+	hnFetcher := mock_services.NewMockRetriever(ctrl)
+	hnFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, maxItems int) ([]data.Item, error) {
+		return hnItems[:min(maxItems, len(hnItems))], nil
+	}).AnyTimes()
+	lobstersFetcher := mock_services.NewMockRetriever(ctrl)
+	lobstersFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, maxItems int) ([]data.Item, error) {
+		return lobstersItems[:min(maxItems, len(lobstersItems))], nil
+	}).AnyTimes()
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": hnFetcher, "lobsters": lobstersFetcher})
+	handler := BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"hn"}})
+
+	tests := []struct {
+		name          string
+		target        string
+		wantStatus    int
+		wantIds       []string
+		wantOrders    []int
+		wantCursor    string
+		wantErrorCode string
+	}{
+		{name: "Default sources", target: "/items?sort=score", wantStatus: http.StatusOK, wantIds: []string{"1", "2", "3"}, wantOrders: []int{1, 2, 3}},
+		{name: "Selected sources", target: "/items?sort=score&sources=hn,lobsters", wantStatus: http.StatusOK, wantIds: []string{"1", "11", "2", "12", "3"}, wantOrders: []int{1, 2, 3, 4, 5}},
+		{name: "Limit and offset", target: "/items?sort=score&limit=2&offset=1", wantStatus: http.StatusOK, wantIds: []string{"2", "3"}, wantOrders: []int{2, 3}},
+		{name: "Next page", target: "/items?sort=score&limit=2", wantStatus: http.StatusOK, wantIds: []string{"1", "2"}, wantOrders: []int{1, 2}, wantCursor: encodeCursor(2)},
+		{name: "Cursor", target: "/items?sort=score&limit=2&cursor=" + encodeCursor(2), wantStatus: http.StatusOK, wantIds: []string{"3"}, wantOrders: []int{3}},
+		{name: "Invalid limit", target: "/items?limit=0", wantStatus: http.StatusBadRequest, wantErrorCode: "invalid_parameter"},
+		{name: "Invalid offset", target: "/items?offset=-1", wantStatus: http.StatusBadRequest, wantErrorCode: "invalid_parameter"},
+		{name: "Offset past the last page", target: "/items?offset=9223372036854775807", wantStatus: http.StatusBadRequest, wantErrorCode: "invalid_parameter"},
+		{name: "Cursor past the last page", target: "/items?limit=200&cursor=" + encodeCursor(maxItemsWindow-199), wantStatus: http.StatusBadRequest, wantErrorCode: "invalid_parameter"},
+		{name: "Malformed cursor", target: "/items?cursor=abc", wantStatus: http.StatusBadRequest, wantErrorCode: "invalid_parameter"},
+		{name: "Offset and cursor", target: "/items?offset=1&cursor=" + encodeCursor(2), wantStatus: http.StatusBadRequest, wantErrorCode: "invalid_parameter"},
+		{name: "Unknown source", target: "/items?sources=hn,reddit", wantStatus: http.StatusBadRequest, wantErrorCode: "unknown_source"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var errorResponse data.ErrorResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil {
+					t.Fatalf("could not unmarshal error response: %v", err)
+				}
+				if errorResponse.Error.Code != tt.wantErrorCode {
+					t.Errorf("handler returned error code %q, want %q", errorResponse.Error.Code, tt.wantErrorCode)
+				}
+				return
+			}
+			var scrapedResult []data.ScraperResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &scrapedResult); err != nil {
+				t.Fatalf("could not unmarshal response: %v", err)
+			}
+			gotIds := make([]string, len(scrapedResult))
+			gotOrders := make([]int, len(scrapedResult))
+			for i, item := range scrapedResult {
+				gotIds[i] = item.Id
+				gotOrders[i] = item.Order
+			}
+			if !reflect.DeepEqual(gotIds, tt.wantIds) || !reflect.DeepEqual(gotOrders, tt.wantOrders) {
+				t.Errorf("handler returned ids %v orders %v, want %v %v", gotIds, gotOrders, tt.wantIds, tt.wantOrders)
+			}
+			if got := rr.Header().Get("X-Next-Cursor"); got != tt.wantCursor {
+				t.Errorf("X-Next-Cursor header = %q, want %q", got, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestRetrieveItemsPages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var hnItems, lobstersItems []data.Item
+	for i := range maxItemsWindow {
+		hnItems = append(hnItems, data.Item{Id: data.ItemId(2*i + 1), Title: fmt.Sprintf("HN %d", i), Score: 2 * (maxItemsWindow - i)})
+		lobstersItems = append(lobstersItems, data.Item{Id: data.ItemId(2*i + 2), Title: fmt.Sprintf("Lobsters %d", i), Score: maxItemsWindow - i})
+	}
+	hnFetcher := mock_services.NewMockRetriever(ctrl)
+	hnFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, maxItems int) ([]data.Item, error) {
+		return hnItems[:min(maxItems, len(hnItems))], nil
+	}).AnyTimes()
+	lobstersFetcher := mock_services.NewMockRetriever(ctrl)
+	lobstersFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, maxItems int) ([]data.Item, error) {
+		return lobstersItems[:min(maxItems, len(lobstersItems))], nil
+	}).AnyTimes()
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": hnFetcher, "lobsters": lobstersFetcher})
+	handler := BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"hn", "lobsters"}})
+
+	get := func(target string) ([]data.ScraperResponse, string) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s returned status %v, want %v", target, rr.Code, http.StatusOK)
+		}
+		var page []data.ScraperResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatalf("could not unmarshal response: %v", err)
+		}
+		return page, rr.Header().Get("X-Next-Cursor")
+	}
+
+	whole, _ := get(fmt.Sprintf("/items?sort=score&limit=%d", maxLimit))
+	var paged []data.ScraperResponse
+	target, pages := "/items?sort=score&limit=7", 0
+	for {
+		page, cursor := get(target)
+		paged = append(paged, page...)
+		if pages++; len(paged) >= len(whole) || cursor == "" {
+			break
+		}
+		target = "/items?sort=score&limit=7&cursor=" + cursor
+	}
+	if !reflect.DeepEqual(paged[:len(whole)], whole) {
+		t.Errorf("pages do not continue the ranking of a single page")
+	}
+
+	last := fmt.Sprintf("/items?limit=%d&cursor=%s", maxLimit, encodeCursor(maxItemsWindow-2*maxLimit))
+	if _, cursor := get(last); cursor == "" {
+		t.Errorf("X-Next-Cursor header missing before the last page of the window")
+	}
+	last = fmt.Sprintf("/items?limit=%d&cursor=%s", maxLimit, encodeCursor(maxItemsWindow-maxLimit))
+	if page, cursor := get(last); len(page) != maxLimit || cursor != "" {
+		t.Errorf("last page of the window returned %d items and cursor %q, want %d items and no cursor", len(page), cursor, maxLimit)
+	}
+}
+
+func TestRetrieveFilteredItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	hnItems := []data.Item{
+		{Id: 1, Title: "Go 1.23 released", Url: "https://go.dev/blog/go1.23", By: "rsc", Score: 90, Descendants: 40, Type: "story", Time: int(day.Add(10 * time.Hour).Unix())},
+		{Id: 2, Title: "Ask HN: Who is hiring?", Url: "https://news.ycombinator.com/item?id=2", By: "whoishiring", Score: 60, Descendants: 300, Type: "story", Time: int(day.Add(-2 * time.Hour).Unix())},
+		{Id: 3, Title: "Rust in the kernel", Url: "https://lwn.net/Articles/1", By: "corbet", Score: 80, Descendants: 10, Type: "story", Time: int(day.Add(5 * time.Hour).Unix())},
+		{Id: 4, Title: "Acme is hiring Go engineers", Url: "https://www.acme.com/jobs", By: "acme", Score: 1, Type: "job", Time: int(day.Add(20 * time.Hour).Unix())},
+	}
+	lobstersItems := []data.Item{{Id: 11, Title: "Generics in Go", Url: "https://blog.go.dev/generics", By: "RSC", Score: 30, Descendants: 5, Type: "story", Time: int(day.Add(12 * time.Hour).Unix())}}
+	hnFetcher := mock_services.NewMockRetriever(ctrl)
+	hnFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, maxItems int) ([]data.Item, error) {
+		return hnItems[:min(maxItems, len(hnItems))], nil
+	}).AnyTimes()
+	lobstersFetcher := mock_services.NewMockRetriever(ctrl)
+	lobstersFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(lobstersItems, nil).AnyTimes()
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": hnFetcher, "lobsters": lobstersFetcher})
+	handler := BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"hn", "lobsters"}})
+
+	tests := []struct {
+		name          string
+		target        string
+		wantIds       []string
+		wantErrorCode string
+	}{
+		{name: "Title substring", target: "/items?sort=score&q=GO", wantIds: []string{"1", "11", "4"}},
+		{name: "Title regular expression", target: "/items?sort=score&q=/^(ask|rust)/", wantIds: []string{"3", "2"}},
+		{name: "Domain and subdomains", target: "/items?sort=score&domain=go.dev", wantIds: []string{"1", "11"}},
+		{name: "Excluded domains", target: "/items?sort=score&exclude_domain=go.dev,ycombinator.com", wantIds: []string{"3", "4"}},
+		{name: "Submitter", target: "/items?sort=score&by=rsc", wantIds: []string{"1", "11"}},
+		{name: "Minimum score and comments", target: "/items?sort=score&min_score=50&min_comments=20", wantIds: []string{"1", "2"}},
+		{name: "Type", target: "/items?sort=score&type=job", wantIds: []string{"4"}},
+		{name: "Time window", target: "/items?sort=score&since=2024-06-01T00:00:00Z&until=2024-06-01T12:00:00Z", wantIds: []string{"1", "3", "11"}},
+		{name: "Filtered before limit", target: "/items?sort=score&sources=hn&type=job&limit=1", wantIds: []string{"4"}},
+		{name: "Nothing matching", target: "/items?q=cobol", wantIds: []string{}},
+		{name: "Invalid regular expression", target: "/items?q=/(/", wantErrorCode: "invalid_parameter"},
+		{name: "Invalid minimum score", target: "/items?min_score=-1", wantErrorCode: "invalid_parameter"},
+		{name: "Invalid time", target: "/items?since=yesterday", wantErrorCode: "invalid_parameter"},
+		{name: "Inverted time window", target: "/items?since=2024-06-02T00:00:00Z&until=2024-06-01T00:00:00Z", wantErrorCode: "invalid_parameter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+			if tt.wantErrorCode != "" {
+				var errorResponse data.ErrorResponse
+				if rr.Code != http.StatusBadRequest {
+					t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil || errorResponse.Error.Code != tt.wantErrorCode {
+					t.Errorf("handler returned error %+v, want code %q", errorResponse.Error, tt.wantErrorCode)
+				}
+				return
+			}
+			if rr.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+			var scrapedResult []data.ScraperResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &scrapedResult); err != nil {
+				t.Fatalf("could not unmarshal response: %v", err)
+			}
+			gotIds := make([]string, len(scrapedResult))
+			for i, item := range scrapedResult {
+				gotIds[i] = item.Id
+			}
+			if !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("handler returned ids %v, want %v", gotIds, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestRetrieveHackerNewsList(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	topFetcher := mock_services.NewMockRetriever(ctrl)
+	topFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return([]data.Item{{Id: 1, Title: "Top story", Type: "story", Url: "https://example.com"}}, nil).AnyTimes()
+	askFetcher := mock_services.NewMockRetriever(ctrl)
+	askFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return([]data.Item{{Id: 2, Title: "Ask HN: Anything", Type: "story", Text: "Question", Url: "https://news.ycombinator.com/item?id=2"}}, nil).AnyTimes()
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": topFetcher, "hn-ask": askFetcher})
+	handler := BuildHackerNewsListHandler(registry, map[string]string{"top": "hn", "ask": "hn-ask"}, "top", itemsDefaults{})
+	router := mux.NewRouter()
+	router.HandleFunc("/hacker-news-items", handler)
+	router.HandleFunc("/hacker-news-items/{list}", handler)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantId     string
+		wantText   string
+	}{
+		{name: "Default list", target: "/hacker-news-items", wantStatus: http.StatusOK, wantId: "1"},
+		{name: "List parameter", target: "/hacker-news-items?list=ask", wantStatus: http.StatusOK, wantId: "2", wantText: "Question"},
+		{name: "List route", target: "/hacker-news-items/ask", wantStatus: http.StatusOK, wantId: "2", wantText: "Question"},
+		{name: "Unknown list", target: "/hacker-news-items/poll", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var scrapedResult []data.ScraperResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &scrapedResult); err != nil {
+				t.Fatalf("could not unmarshal response: %v", err)
+			}
+			if len(scrapedResult) != 1 || scrapedResult[0].Id != tt.wantId || scrapedResult[0].Text != tt.wantText {
+				t.Errorf("handler returned %+v, want item %s with text %q", scrapedResult, tt.wantId, tt.wantText)
+			}
+		})
+	}
+}
+
+// commentsFetcher is a source serving a fixed comment thread.
+type commentsFetcher struct {
+	*mock_services.MockRetriever
+	thread data.CommentThread
+}
+
+func (f *commentsFetcher) GetComments(_ context.Context, storyId string, options services.CommentOptions) (data.CommentThread, error) {
+	if storyId != f.thread.StoryId {
+		return data.CommentThread{}, services.ErrStoryNotFound
+	}
+	thread := f.thread
+	if len(thread.Comments) > options.MaxComments {
+		thread.Comments, thread.Truncated = thread.Comments[:options.MaxComments], true
+	}
+	return thread, nil
+}
+
+func TestRetrieveComments(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	thread := data.CommentThread{StoryId: "1", Comments: []data.Comment{
+		{Id: "10", By: "bob", Time: 1717194200, Text: "First", Replies: []data.Comment{{Id: "100", By: "dave", Time: 1717194300, Text: "Reply"}}},
+		{Id: "11", Time: 1717194210, Deleted: true},
+	}}
+	hnFetcher := &commentsFetcher{MockRetriever: mock_services.NewMockRetriever(ctrl), thread: thread}
+	feedFetcher := mock_services.NewMockRetriever(ctrl)
+	downFetcher := &commentsFetcher{MockRetriever: mock_services.NewMockRetriever(ctrl), thread: thread}
+	downFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(nil, errors.New("source down"))
+	downBreaker := services.NewCircuitBreaker("down", downFetcher, services.BreakerConfig{FailureThreshold: 1, CoolDown: time.Hour})
+	downBreaker.GetItems(context.Background(), 10)
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": services.NewCircuitBreaker("hn", hnFetcher, services.DefaultBreakerConfig), "feed": feedFetcher, "down": downBreaker})
+	router := mux.NewRouter()
+	router.HandleFunc("/items/{source}/{id}/comments", BuildCommentsHandler(registry))
+
+	tests := []struct {
+		name          string
+		target        string
+		wantStatus    int
+		wantComments  int
+		wantTruncated bool
+	}{
+		{name: "Comment thread", target: "/items/hn/1/comments", wantStatus: http.StatusOK, wantComments: 2},
+		{name: "Limited thread", target: "/items/hn/1/comments?max_comments=1", wantStatus: http.StatusOK, wantComments: 1, wantTruncated: true},
+		{name: "Unknown story", target: "/items/hn/2/comments", wantStatus: http.StatusNotFound},
+		{name: "Unknown source", target: "/items/reddit/1/comments", wantStatus: http.StatusNotFound},
+		{name: "Source without comments", target: "/items/feed/1/comments", wantStatus: http.StatusBadRequest},
+		{name: "Source with open circuit", target: "/items/down/1/comments", wantStatus: http.StatusServiceUnavailable},
+		{name: "Invalid depth", target: "/items/hn/1/comments?max_depth=0", wantStatus: http.StatusBadRequest},
+		{name: "Invalid size", target: "/items/hn/1/comments?max_comments=5000", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var errorResponse data.ErrorResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil || errorResponse.Error.Code == "" {
+					t.Errorf("handler returned unstructured error %q", rr.Body.String())
+				}
+				return
+			}
+			var got data.CommentThread
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("could not unmarshal response: %v", err)
+			}
+			if len(got.Comments) != tt.wantComments || got.Truncated != tt.wantTruncated {
+				t.Errorf("handler returned %+v, want %d comments truncated %v", got, tt.wantComments, tt.wantTruncated)
+			}
+			if !reflect.DeepEqual(got.Comments[0], thread.Comments[0]) {
+				t.Errorf("handler returned comment %+v, want %+v", got.Comments[0], thread.Comments[0])
+			}
+		})
+	}
+}
+
+func TestRetrieveItemsHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	itemStore, err := store.OpenBoltStore(filepath.Join(t.TempDir(), "items.db"))
+	if err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+	defer itemStore.Close()
+	morning := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	noon := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	itemStore.Record("hn", morning, []data.Item{{Id: 2, Title: "Other story", Score: 50}, {Id: 1, Title: "Climbing story", Score: 10}})
+	itemStore.Record("hn", noon, []data.Item{{Id: 1, Title: "Climbing story", Score: 120}, {Id: 2, Title: "Other story", Score: 60}})
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": mock_services.NewMockRetriever(ctrl)})
+	router := mux.NewRouter()
+	router.HandleFunc("/items/{source}/{id}/history", BuildItemHistoryHandler(registry, itemStore))
+	router.HandleFunc("/front-pages/{source}", BuildFrontPageHandler(registry, itemStore))
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantScores []int
+	}{
+		{name: "Item history", target: "/items/hn/1/history", wantStatus: http.StatusOK, wantScores: []int{10, 120}},
+		{name: "Item history range", target: "/items/hn/1/history?from=2024-06-01T10:00:00Z", wantStatus: http.StatusOK, wantScores: []int{120}},
+		{name: "Unknown item", target: "/items/hn/3/history", wantStatus: http.StatusNotFound},
+		{name: "Invalid time", target: "/items/hn/1/history?to=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "Latest front page", target: "/front-pages/hn", wantStatus: http.StatusOK, wantScores: []int{120, 60}},
+		{name: "Past front page", target: "/front-pages/hn?at=2024-06-01T10:00:00Z", wantStatus: http.StatusOK, wantScores: []int{50, 10}},
+		{name: "Front page before any fetch", target: "/front-pages/hn?at=2024-05-01T10:00:00Z", wantStatus: http.StatusNotFound},
+		{name: "Unknown source", target: "/front-pages/reddit", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response struct {
+				Snapshots []data.ItemSnapshot `json:"snapshots"`
+				Items     []data.ItemSnapshot `json:"items"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("could not unmarshal response: %v", err)
+			}
+			gotScores := make([]int, 0)
+			for _, snapshot := range append(response.Snapshots, response.Items...) {
+				gotScores = append(gotScores, snapshot.Score)
+			}
+			if !reflect.DeepEqual(gotScores, tt.wantScores) {
+				t.Errorf("handler returned scores %v, want %v", gotScores, tt.wantScores)
+			}
+		})
+	}
+}
+
+// snapshotFetcher is a source serving items fetched at a given time.
+type snapshotFetcher struct {
+	items     []data.Item
+	fetchedAt time.Time
+}
+
+func (f *snapshotFetcher) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	items, _, err := f.GetSnapshot(ctx, maxItems)
+	return items, err
+}
+
+func (f *snapshotFetcher) GetSnapshot(_ context.Context, maxItems int) ([]data.Item, time.Time, error) {
+	return f.items[:min(maxItems, len(f.items))], f.fetchedAt, nil
+}
+
+func TestRetrievePolledItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	polledFetcher := &snapshotFetcher{items: []data.Item{{Id: 1, Title: "Polled story"}}, fetchedAt: time.Now().Add(-90 * time.Second)}
+	liveFetcher := mock_services.NewMockRetriever(ctrl)
+	liveFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return([]data.Item{{Id: 2, Title: "Live story"}}, nil)
+	registry := newTestRegistry(t, map[string]services.Retriever{"polled": polledFetcher, "live": liveFetcher})
+
+	rr := httptest.NewRecorder()
+	BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"polled", "live"}}).ServeHTTP(rr, httptest.NewRequest("GET", "/items", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	age, err := strconv.Atoi(rr.Header().Get("X-Data-Age"))
+	if err != nil || age < 90 || age > 95 {
+		t.Errorf("X-Data-Age header = %q, want the age of the polled items", rr.Header().Get("X-Data-Age"))
+	}
+	if got := rr.Header().Values("X-Source-Status"); len(got) != 2 || !strings.Contains(got[0], "; age=") {
+		t.Errorf("X-Source-Status headers = %q, want the age of every source", got)
+	}
+}
+
+func TestRetrieveItemsConditionalRequests(t *testing.T) {
+	fetchedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	fetcher := &snapshotFetcher{items: []data.Item{{Id: 1, Title: "Polled story"}, {Id: 2, Title: "Another polled story"}}, fetchedAt: fetchedAt}
+	registry := newTestRegistry(t, map[string]services.Retriever{"polled": fetcher})
+	handler := BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"polled"}})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/items", nil))
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("handler returned status %v with ETag %q, want %v with an ETag", rr.Code, etag, http.StatusOK)
+	}
+	if got := rr.Header().Get("Last-Modified"); got != "Sat, 01 Jun 2024 12:00:00 GMT" {
+		t.Errorf("Last-Modified header = %q, want the fetch time", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=10" {
+		t.Errorf("Cache-Control header = %q, want %q", got, "public, max-age=10")
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{name: "Matching ETag", target: "/items", headers: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusNotModified},
+		{name: "Weak matching ETag in a list", target: "/items", headers: map[string]string{"If-None-Match": `"other", W/` + etag}, wantStatus: http.StatusNotModified},
+		{name: "Any ETag", target: "/items", headers: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified},
+		{name: "Changed ETag", target: "/items", headers: map[string]string{"If-None-Match": `"other"`}, wantStatus: http.StatusOK},
+		{name: "Other page", target: "/items?limit=1", headers: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusOK},
+		{name: "Not modified since", target: "/items", headers: map[string]string{"If-Modified-Since": "Sat, 01 Jun 2024 12:00:00 GMT"}, wantStatus: http.StatusNotModified},
+		{name: "Modified since", target: "/items", headers: map[string]string{"If-Modified-Since": "Sat, 01 Jun 2024 11:59:59 GMT"}, wantStatus: http.StatusOK},
+		{name: "ETag takes precedence", target: "/items", headers: map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": "Sat, 01 Jun 2024 12:00:00 GMT"}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotModified && (rr.Body.Len() != 0 || rr.Header().Get("ETag") != etag) {
+				t.Errorf("handler returned body %q with ETag %q, want no body with ETag %q", rr.Body.String(), rr.Header().Get("ETag"), etag)
+			}
+		})
+	}
+}
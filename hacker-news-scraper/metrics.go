@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_http_requests_total",
+		Help: "Requests served by every route, by method and status code.",
+	}, []string{"route", "method", "code"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scraper_http_request_duration_seconds",
+		Help:    "Time taken to serve the requests of every route, streams included.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+	httpResponseSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scraper_http_response_size_bytes",
+		Help:    "Size of the responses of every route.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+	}, []string{"route", "method", "code"})
+	httpRequestsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scraper_http_requests_in_flight",
+		Help: "Requests of every route being served, open streams and subscriptions included.",
+	}, []string{"route"})
+)
+
+// instrumentRoutes wraps the handler of every route of router, labelling
+// its request metrics with the route path template and tracing its requests
+// in spans named after it. Routes must be registered before.
+func instrumentRoutes(router *mux.Router) error {
+	return router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		handler := route.GetHandler()
+		template, err := route.GetPathTemplate()
+		if handler == nil || err != nil {
+			return nil
+		}
+		labels := prometheus.Labels{"route": template}
+		handler = promhttp.InstrumentHandlerResponseSize(httpResponseSize.MustCurryWith(labels), handler)
+		handler = promhttp.InstrumentHandlerCounter(httpRequests.MustCurryWith(labels), handler)
+		handler = promhttp.InstrumentHandlerDuration(httpRequestDuration.MustCurryWith(labels), handler)
+		handler = promhttp.InstrumentHandlerInFlight(httpRequestsInFlight.With(labels), handler)
+		route.Handler(otelhttp.NewHandler(handler, template, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + template
+		})))
+		return nil
+	})
+}
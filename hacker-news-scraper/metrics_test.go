@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentRoutes(t *testing.T) {
+	first := data.Item{Id: 1, Title: "First", Score: 10}
+	fetcher := &rankingsFetcher{rankings: [][]data.Item{{first}, {first}}, done: make(chan struct{})}
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": fetcher})
+	r := mux.NewRouter()
+	r.HandleFunc("/metrics-test/items", BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"hn"}})).Methods("GET")
+	r.HandleFunc("/metrics-test/items/stream", BuildItemsStreamHandler(registry, itemsDefaults{sources: []string{"hn"}}, 5*time.Millisecond)).Methods("GET")
+	r.HandleFunc("/metrics-test/items/{source}/{id}/comments", BuildCommentsHandler(registry)).Methods("GET")
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	if err := instrumentRoutes(r); err != nil {
+		t.Fatalf("could not instrument routes: %v", err)
+	}
+
+	requests := []struct {
+		target     string
+		wantStatus int
+	}{
+		{target: "/metrics-test/items", wantStatus: http.StatusOK},
+		{target: "/metrics-test/items?limit=0", wantStatus: http.StatusBadRequest},
+		{target: "/metrics-test/items/hn/1/comments", wantStatus: http.StatusBadRequest},
+		{target: "/metrics-test/items/reddit/1/comments", wantStatus: http.StatusNotFound},
+	}
+	for _, request := range requests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", request.target, nil))
+		if rr.Code != request.wantStatus {
+			t.Fatalf("GET %s returned status %v, want %v", request.target, rr.Code, request.wantStatus)
+		}
+	}
+
+	// Streams keep flushing their events through the instrumented writer.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-fetcher.done
+		cancel()
+	}()
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics-test/items/stream", nil).WithContext(ctx))
+	if rr.Code != http.StatusOK || !rr.Flushed {
+		t.Errorf("stream returned status %v, flushed %v, want %v flushed", rr.Code, rr.Flushed, http.StatusOK)
+	}
+
+	counts := []struct {
+		route string
+		code  string
+		want  float64
+	}{
+		{route: "/metrics-test/items", code: "200", want: 1},
+		{route: "/metrics-test/items", code: "400", want: 1},
+		{route: "/metrics-test/items/{source}/{id}/comments", code: "400", want: 1},
+		{route: "/metrics-test/items/{source}/{id}/comments", code: "404", want: 1},
+		{route: "/metrics-test/items/stream", code: "200", want: 1},
+	}
+	for _, count := range counts {
+		if got := testutil.ToFloat64(httpRequests.WithLabelValues(count.route, "get", count.code)); got != count.want {
+			t.Errorf("scraper_http_requests_total of %s %s = %v, want %v", count.route, count.code, got, count.want)
+		}
+	}
+	if got := testutil.ToFloat64(httpRequestsInFlight.WithLabelValues("/metrics-test/items/stream")); got != 0 {
+		t.Errorf("scraper_http_requests_in_flight of the stream = %v, want 0", got)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	body := rr.Body.String()
+	for _, metric := range []string{`scraper_http_requests_total{code="200",method="get",route="/metrics-test/items"} 1`, "scraper_http_request_duration_seconds_bucket", "scraper_aggregator_merge_duration_seconds_count"} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("metrics do not expose %s", metric)
+		}
+	}
+}
@@ -1,71 +1,100 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
 	"io"
-	"log"
-	"math"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
+const defaultMaxConcurrency = 8
+const maxPollOptions = 20
+
 type APIConnector struct {
 	Url              string
 	ItemsEndPoint    string
 	ItemDataEndPoint string
+	// Client is used for every request. http.DefaultClient is used when nil.
+	Client *http.Client
+	// MaxConcurrency caps the item data requests in flight. Defaults to 8.
+	MaxConcurrency int
+	// DiscussionUrl is the format, taking the item id, of the web page of an
+	// item, used as Url of the items without link such as Ask HN stories.
+	DiscussionUrl string
 }
 
 type itemError string
 
 func (s itemError) Error() string { return string(s) }
 
-func (c *APIConnector) GetItems(maxItems int) ([]data.Item, error) {
+func (c *APIConnector) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
 	reqUrl := fmt.Sprintf("%s/%s.json", c.Url, c.ItemsEndPoint)
-	resp, err := http.Get(reqUrl)
+	resp, err := c.get(ctx, reqUrl)
 	if err != nil {
-		log.Printf("Failed to make request: %v", err)
+		slog.WarnContext(ctx, "Failed to make request", "url", reqUrl, "error", err)
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to make request: %s", resp.Status)
-		var statusError itemError = itemError("Response status: " + resp.Status)
-		return nil, statusError
+		slog.WarnContext(ctx, "Failed to make request", "url", reqUrl, "status", resp.Status)
+		return nil, StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
-	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
+		slog.WarnContext(ctx, "Failed to read response body", "url", reqUrl, "error", err)
 		return nil, err
 	}
 
 	var identifiers []data.ItemId
 	if err := json.Unmarshal(body, &identifiers); err != nil {
-		log.Printf("Failed to unmarshal JSON: %v", err)
+		slog.WarnContext(ctx, "Failed to unmarshal JSON", "url", reqUrl, "error", err)
 	}
 
-	numItems := int(math.Min(float64(len(identifiers)), float64(maxItems)))
-	itemChannel := make(chan data.Item)
+	numItems := max(0, min(len(identifiers), maxItems))
+	// Every item is kept at the index of its identifier, so that items keep
+	// the order of the list whichever worker fetches them first.
+	results := make([]data.Item, numItems)
+	indexChannel := make(chan int)
+	var failures atomic.Int32
 	waitGroup := sync.WaitGroup{}
-	waitGroup.Add(numItems)
+	workers := min(c.maxConcurrency(), numItems)
+	waitGroup.Add(workers)
+	for range workers {
+		go c.itemDataWorker(ctx, identifiers, indexChannel, results, &failures, &waitGroup)
+	}
 	for i := range numItems {
-		go c.getItemData(identifiers[i], itemChannel, &waitGroup)
+		select {
+		case indexChannel <- i:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
 	}
+	close(indexChannel)
 	//wait for all items retrieved
-	go func() {
-		waitGroup.Wait()
-		close(itemChannel)
-	}()
+	waitGroup.Wait()
 
-	items := make([]data.Item, 0)
-	for item := range itemChannel {
-		items = append(items, item)
+	items := make([]data.Item, 0, numItems)
+	for _, item := range results {
+		if item.Id != 0 {
+			items = append(items, item)
+		}
 	}
 
-	if len(items) < numItems {
+	if err := ctx.Err(); err != nil {
+		slog.InfoContext(ctx, "Abandoned items retrieval", "error", err)
+		return nil, err
+	}
+	if failures.Load() > 0 {
 		var itemsErr itemError = "There has been an error getting some item"
 		return items, itemsErr
 	} else {
@@ -73,28 +102,173 @@ func (c *APIConnector) GetItems(maxItems int) ([]data.Item, error) {
 	}
 }
 
-func (c *APIConnector) getItemData(identifier data.ItemId, channel chan data.Item, waitGroup *sync.WaitGroup) {
+// itemDataWorker fetches the items of the identifiers at the indexes
+// received, storing every item at its index of results. Items failing or
+// removed are left zero.
+func (c *APIConnector) itemDataWorker(ctx context.Context, identifiers []data.ItemId, indexes <-chan int, results []data.Item, failures *atomic.Int32, waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+	apiConnectorInFlight.Inc()
+	defer apiConnectorInFlight.Dec()
+	for i := range indexes {
+		item, ok := c.getItemData(ctx, identifiers[i])
+		switch {
+		case !ok:
+			failures.Add(1)
+		case item.Id == 0 || item.Deleted || item.Dead:
+			// Removed items are still listed for a while, they are just skipped.
+		default:
+			results[i] = c.completeItem(ctx, item)
+		}
+	}
+}
+
+// completeItem adapts items not being plain linked stories: Ask HN stories,
+// jobs and polls without link get their discussion page as Url, and polls
+// get their options resolved.
+func (c *APIConnector) completeItem(ctx context.Context, item data.Item) data.Item {
+	if item.Url == "" && c.DiscussionUrl != "" {
+		item.Url = fmt.Sprintf(c.DiscussionUrl, item.Id)
+	}
+	if item.Type == "poll" {
+		for _, part := range item.Parts[:min(len(item.Parts), maxPollOptions)] {
+			if option, ok := c.getItemData(ctx, data.ItemId(part)); ok && !option.Deleted && !option.Dead {
+				item.PollOptions = append(item.PollOptions, data.PollOption{Text: option.Text, Score: option.Score})
+			}
+		}
+	}
+	return item
+}
 
+func (c *APIConnector) getItemData(ctx context.Context, identifier data.ItemId) (data.Item, bool) {
+	var item data.Item
 	reqUrl := fmt.Sprintf("%s/%s/%d.json", c.Url, c.ItemDataEndPoint, identifier)
-	resp, err := http.Get(reqUrl)
+	resp, err := c.get(ctx, reqUrl)
 	if err != nil {
-		log.Printf("Failed to make request: %v", err)
-		waitGroup.Done()
-		return
+		slog.WarnContext(ctx, "Failed to make request", "url", reqUrl, "error", err)
+		return item, false
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.WarnContext(ctx, "Failed to make request", "url", reqUrl, "status", resp.Status)
+		return item, false
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Failed to read response body: %v\n", err)
-		waitGroup.Done()
-		return
+		slog.WarnContext(ctx, "Failed to read response body", "url", reqUrl, "error", err)
+		return item, false
 	}
-	var item data.Item
 	if err := json.Unmarshal(body, &item); err != nil {
-		log.Printf("Failed to unmarshal JSON: %v\n", err)
-		waitGroup.Done()
-		return
+		slog.WarnContext(ctx, "Failed to unmarshal JSON", "url", reqUrl, "error", err)
+		return item, false
+	}
+	return item, true
+}
+
+func (c *APIConnector) get(ctx context.Context, reqUrl string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+func (c *APIConnector) maxConcurrency() int {
+	if c.MaxConcurrency > 0 {
+		return c.MaxConcurrency
+	}
+	return defaultMaxConcurrency
+}
+
+func (c *APIConnector) GetComments(ctx context.Context, storyId string, options CommentOptions) (data.CommentThread, error) {
+	thread := data.CommentThread{StoryId: storyId, Comments: make([]data.Comment, 0)}
+	identifier, err := strconv.ParseInt(storyId, 10, 64)
+	if err != nil {
+		return thread, ErrStoryNotFound
+	}
+	story, ok := c.getItemData(ctx, data.ItemId(identifier))
+	if err := ctx.Err(); err != nil {
+		return thread, err
+	}
+	if !ok {
+		return thread, itemError("There has been an error getting the story")
+	}
+	if story.Id == 0 {
+		return thread, ErrStoryNotFound
+	}
+
+	walker := &commentWalker{connector: c, options: options, budget: newCommentBudget(options.MaxComments), slots: make(chan struct{}, c.maxConcurrency())}
+	if comments := walker.walk(ctx, story.Kids, 1); comments != nil {
+		thread.Comments = comments
+	}
+	if err := ctx.Err(); err != nil {
+		return thread, err
+	}
+	if walker.failures.Load() > 0 {
+		return thread, itemError("There has been an error getting some comment")
+	}
+	thread.Truncated = walker.budget.truncated.Load() || walker.deeper.Load()
+	return thread, nil
+}
+
+// commentWalker fetches a comment tree level by level, every reply of a
+// comment being fetched concurrently while slots bound the requests in flight.
+type commentWalker struct {
+	connector *APIConnector
+	options   CommentOptions
+	budget    *commentBudget
+	slots     chan struct{}
+	failures  atomic.Int32
+	deeper    atomic.Bool
+}
+
+func (w *commentWalker) walk(ctx context.Context, kids []int, depth int) []data.Comment {
+	// The comments of a level are reserved before fetching any reply, so the
+	// size limit keeps the upper levels of the thread.
+	reserved := 0
+	for reserved < len(kids) && w.budget.take() {
+		reserved++
+	}
+	comments := make([]*data.Comment, reserved)
+	var waitGroup sync.WaitGroup
+	for i, kid := range kids[:reserved] {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			select {
+			case w.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			apiConnectorInFlight.Inc()
+			item, ok := w.connector.getItemData(ctx, data.ItemId(kid))
+			apiConnectorInFlight.Dec()
+			<-w.slots
+			if !ok {
+				w.failures.Add(1)
+				return
+			} else if item.Id == 0 {
+				return
+			}
+			comment := data.Comment{Id: strconv.Itoa(int(item.Id)), By: item.By, Time: item.Time, Text: item.Text, Deleted: item.Deleted, Dead: item.Dead}
+			if len(item.Kids) > 0 && depth < w.options.MaxDepth {
+				comment.Replies = w.walk(ctx, item.Kids, depth+1)
+			} else if len(item.Kids) > 0 {
+				w.deeper.Store(true)
+			}
+			comments[i] = &comment
+		}()
+	}
+	waitGroup.Wait()
+
+	var thread []data.Comment
+	for _, comment := range comments {
+		if comment != nil {
+			thread = append(thread, *comment)
+		}
 	}
-	channel <- item
-	waitGroup.Done()
+	return thread
 }
@@ -1,13 +1,21 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
 	"github.com/jarcoal/httpmock"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestAPIConnector_GetItems(t *testing.T) {
@@ -83,7 +91,7 @@ func TestAPIConnector_GetItems(t *testing.T) {
 				ItemsEndPoint:    tt.fields.ItemsEndPoint,
 				ItemDataEndPoint: tt.fields.ItemDataEndPoint,
 			}
-			got, err := c.GetItems(tt.fields.MaxResults)
+			got, err := c.GetItems(context.Background(), tt.fields.MaxResults)
 			if err != nil {
 				switch {
 				case !tt.wantErr:
@@ -108,3 +116,203 @@ func TestAPIConnector_GetItems(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIConnector_GetItemsBoundedConcurrency(t *testing.T) {
+	const numItems = 20
+	const maxConcurrency = 3
+	var inFlight, maxInFlight atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ids.json", func(w http.ResponseWriter, r *http.Request) {
+		ids := make([]string, numItems)
+		for i := range numItems {
+			ids[i] = strconv.Itoa(i + 1)
+		}
+		w.Write([]byte(fmt.Sprintf("[%s]", strings.Join(ids, ","))))
+	})
+	mux.HandleFunc("/item/", func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/item/"), ".json")
+		w.Write([]byte(fmt.Sprintf(`{"id":%s,"title":"Item %s","type":"story"}`, id, id)))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c := &APIConnector{Url: ts.URL, ItemsEndPoint: "ids", ItemDataEndPoint: "item", Client: ts.Client(), MaxConcurrency: maxConcurrency}
+	got, err := c.GetItems(context.Background(), numItems)
+	if err != nil {
+		t.Fatalf("GetItems() error = %v", err)
+	}
+	if len(got) != numItems {
+		t.Errorf("GetItems() got %d items, want %d", len(got), numItems)
+	}
+	if maxInFlight.Load() > maxConcurrency {
+		t.Errorf("GetItems() reached %d concurrent requests, want at most %d", maxInFlight.Load(), maxConcurrency)
+	}
+}
+
+func TestAPIConnector_GetItemsOrder(t *testing.T) {
+	listed := []data.ItemId{7, 3, 9, 1, 5, 8, 2, 6, 4}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/topstories.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(listed)
+	})
+	mux.HandleFunc("/item/", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/item/"), ".json"), 10, 64)
+		// The first listed items are the slowest to answer.
+		time.Sleep(time.Duration(len(listed)-slices.Index(listed, data.ItemId(id))) * 2 * time.Millisecond)
+		w.Write([]byte(fmt.Sprintf(`{"id":%d,"title":"Item %d","type":"story"}`, id, id)))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c := &APIConnector{Url: ts.URL, ItemsEndPoint: "topstories", ItemDataEndPoint: "item", Client: ts.Client(), MaxConcurrency: 4}
+	got, err := c.GetItems(context.Background(), len(listed))
+	if err != nil {
+		t.Fatalf("GetItems() error = %v", err)
+	}
+	gotIds := make([]data.ItemId, len(got))
+	for i, item := range got {
+		gotIds[i] = item.Id
+	}
+	if !slices.Equal(gotIds, listed) {
+		t.Errorf("GetItems() got items %v, want the listed order %v", gotIds, listed)
+	}
+}
+
+func TestAPIConnector_GetItemsFailingItem(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/topstories.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[1,2]"))
+	})
+	mux.HandleFunc("/item/1.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1,"title":"Item 1","type":"story"}`))
+	})
+	mux.HandleFunc("/item/2.json", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"rate limited"}`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c := &APIConnector{Url: ts.URL, ItemsEndPoint: "topstories", ItemDataEndPoint: "item", Client: ts.Client()}
+	got, err := c.GetItems(context.Background(), 2)
+	if err == nil {
+		t.Errorf("GetItems() error = nil, want an error for the item answered with %d", http.StatusTooManyRequests)
+	}
+	if len(got) != 1 || got[0].Id != 1 {
+		t.Errorf("GetItems() got %v, want only item 1", got)
+	}
+}
+
+func TestAPIConnector_GetItemsItemTypes(t *testing.T) {
+	itemsData := map[string]string{
+		"1":  `{"by":"alice","descendants":3,"id":1,"kids":[10],"score":50,"time":1717194188,"title":"A linked story","type":"story","url":"https://example.com/story"}`,
+		"2":  `{"by":"bob","descendants":8,"id":2,"kids":[20],"score":30,"text":"What are you working on?","time":1717194188,"title":"Ask HN: Projects","type":"story"}`,
+		"3":  `{"by":"carol","id":3,"score":1,"text":"We are hiring","time":1717194188,"title":"Acme (YC S24) is hiring","type":"job"}`,
+		"4":  `{"by":"dave","descendants":2,"id":4,"parts":[41,42],"score":12,"text":"Favourite editor?","time":1717194188,"title":"Poll: Editors","type":"poll"}`,
+		"41": `{"by":"dave","id":41,"poll":4,"score":7,"text":"vim","time":1717194188,"type":"pollopt"}`,
+		"42": `{"by":"dave","id":42,"poll":4,"score":5,"text":"emacs","time":1717194188,"type":"pollopt"}`,
+		"5":  `{"deleted":true,"id":5,"time":1717194188,"type":"story"}`,
+		"6":  `null`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/askstories.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[1,2,3,4,5,6]"))
+	})
+	mux.HandleFunc("/item/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/item/"), ".json")
+		w.Write([]byte(itemsData[id]))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c := &APIConnector{Url: ts.URL, ItemsEndPoint: "askstories", ItemDataEndPoint: "item", Client: ts.Client(), DiscussionUrl: "https://news.ycombinator.com/item?id=%d"}
+	got, err := c.GetItems(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("GetItems() error = %v", err)
+	}
+	want := []data.Item{
+		{By: "alice", Descendants: 3, Id: 1, Kids: []int{10}, Score: 50, Time: 1717194188, Title: "A linked story", Type: "story", Url: "https://example.com/story"},
+		{By: "bob", Descendants: 8, Id: 2, Kids: []int{20}, Score: 30, Text: "What are you working on?", Time: 1717194188, Title: "Ask HN: Projects", Type: "story", Url: "https://news.ycombinator.com/item?id=2"},
+		{By: "carol", Id: 3, Score: 1, Text: "We are hiring", Time: 1717194188, Title: "Acme (YC S24) is hiring", Type: "job", Url: "https://news.ycombinator.com/item?id=3"},
+		{By: "dave", Descendants: 2, Id: 4, Parts: []int{41, 42}, Score: 12, Text: "Favourite editor?", Time: 1717194188, Title: "Poll: Editors", Type: "poll", Url: "https://news.ycombinator.com/item?id=4",
+			PollOptions: []data.PollOption{{Text: "vim", Score: 7}, {Text: "emacs", Score: 5}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetItems() got = %+v, want %+v", got, want)
+	}
+}
+
+func TestAPIConnector_GetComments(t *testing.T) {
+	itemsData := map[string]string{
+		"1":    `{"by":"alice","descendants":5,"id":1,"kids":[10,11,12],"score":50,"time":1717194188,"title":"A linked story","type":"story","url":"https://example.com/story"}`,
+		"10":   `{"by":"bob","id":10,"kids":[100],"parent":1,"text":"First","time":1717194200,"type":"comment"}`,
+		"11":   `{"deleted":true,"id":11,"parent":1,"time":1717194210,"type":"comment"}`,
+		"12":   `{"by":"carol","dead":true,"id":12,"parent":1,"text":"[flagged]","time":1717194220,"type":"comment"}`,
+		"100":  `{"by":"dave","id":100,"kids":[1000],"parent":10,"text":"Reply","time":1717194300,"type":"comment"}`,
+		"1000": `{"by":"erin","id":1000,"parent":100,"text":"Nested reply","time":1717194400,"type":"comment"}`,
+		"2":    `{"by":"alice","id":2,"score":1,"time":1717194188,"title":"No comments","type":"story"}`,
+		"3":    `null`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/item/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/item/"), ".json")
+		itemData, ok := itemsData[id]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(itemData))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	nested := data.Comment{Id: "1000", By: "erin", Time: 1717194400, Text: "Nested reply"}
+	reply := data.Comment{Id: "100", By: "dave", Time: 1717194300, Text: "Reply", Replies: []data.Comment{nested}}
+	first := data.Comment{Id: "10", By: "bob", Time: 1717194200, Text: "First", Replies: []data.Comment{reply}}
+	deleted := data.Comment{Id: "11", Time: 1717194210, Deleted: true}
+	dead := data.Comment{Id: "12", By: "carol", Time: 1717194220, Text: "[flagged]", Dead: true}
+	shallowFirst := first
+	shallowFirst.Replies = nil
+	tests := []struct {
+		name    string
+		storyId string
+		options CommentOptions
+		want    data.CommentThread
+		wantErr error
+	}{
+		{name: "Whole thread", storyId: "1", options: CommentOptions{MaxDepth: 5, MaxComments: 10},
+			want: data.CommentThread{StoryId: "1", Comments: []data.Comment{first, deleted, dead}}},
+		{name: "Depth limit", storyId: "1", options: CommentOptions{MaxDepth: 1, MaxComments: 10},
+			want: data.CommentThread{StoryId: "1", Comments: []data.Comment{shallowFirst, deleted, dead}, Truncated: true}},
+		{name: "Size limit", storyId: "1", options: CommentOptions{MaxDepth: 5, MaxComments: 2},
+			want: data.CommentThread{StoryId: "1", Comments: []data.Comment{shallowFirst, deleted}, Truncated: true}},
+		{name: "No comments", storyId: "2", options: CommentOptions{MaxDepth: 5, MaxComments: 10},
+			want: data.CommentThread{StoryId: "2", Comments: []data.Comment{}}},
+		{name: "Unknown story", storyId: "3", options: CommentOptions{MaxDepth: 5, MaxComments: 10}, wantErr: ErrStoryNotFound},
+		{name: "Invalid story id", storyId: "abc", options: CommentOptions{MaxDepth: 5, MaxComments: 10}, wantErr: ErrStoryNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &APIConnector{Url: ts.URL, ItemDataEndPoint: "item", Client: ts.Client(), MaxConcurrency: 2}
+			got, err := c.GetComments(context.Background(), tt.storyId, tt.options)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetComments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetComments() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
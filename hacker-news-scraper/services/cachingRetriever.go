@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+type CacheConfig struct {
+	// TTL is how long fetched items are served without calling the source.
+	TTL time.Duration
+	// StaleWhileRevalidate is how long after the TTL expired items are still
+	// served while the source is refreshed in the background.
+	StaleWhileRevalidate time.Duration
+	// StaleIfError is how long after the TTL expired items are served when
+	// refreshing them fails.
+	StaleIfError time.Duration
+}
+
+var DefaultCacheConfig = CacheConfig{TTL: 30 * time.Second, StaleWhileRevalidate: 2 * time.Minute, StaleIfError: 10 * time.Minute}
+
+// CachingRetriever wraps a Retriever memoizing the largest listing fetched
+// from it, requests of fewer items being served its first items. Concurrent
+// requests of items not cached share a single fetch.
+type CachingRetriever struct {
+	// Source names the cached source in logs.
+	Source    string
+	Retriever Retriever
+	Config    CacheConfig
+
+	now   func() time.Time
+	mu    sync.Mutex
+	entry cacheEntry
+}
+
+type cacheEntry struct {
+	items     []data.Item
+	fetchedAt time.Time
+	// maxItems is the number of items the cached listing was fetched with,
+	// those of fewer items being its first items.
+	maxItems int
+	// fetch is the latest fetch in flight, nil when there is none.
+	fetch *cacheFetch
+}
+
+type cacheFetch struct {
+	done      chan struct{}
+	maxItems  int
+	items     []data.Item
+	fetchedAt time.Time
+	err       error
+}
+
+func NewCachingRetriever(source string, retriever Retriever, config CacheConfig) *CachingRetriever {
+	return &CachingRetriever{Source: source, Retriever: retriever, Config: config, now: time.Now}
+}
+
+func (c *CachingRetriever) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	items, _, err := c.GetSnapshot(ctx, maxItems)
+	return items, err
+}
+
+// GetSnapshot returns the cached items along with the time they were fetched.
+func (c *CachingRetriever) GetSnapshot(ctx context.Context, maxItems int) ([]data.Item, time.Time, error) {
+	c.mu.Lock()
+	entry := &c.entry
+	age := c.now().Sub(entry.fetchedAt)
+	if entry.items != nil && entry.maxItems >= maxItems && age < c.Config.TTL+c.Config.StaleWhileRevalidate {
+		if age >= c.Config.TTL && entry.fetch == nil {
+			c.startFetch(ctx, entry.maxItems)
+		}
+		items, fetchedAt := firstItems(entry.items, maxItems), entry.fetchedAt
+		c.mu.Unlock()
+		return items, fetchedAt, nil
+	}
+	fetch := entry.fetch
+	if fetch == nil || fetch.maxItems < maxItems {
+		// Refreshed listings keep the size of the cached one.
+		fetch = c.startFetch(ctx, max(maxItems, entry.maxItems))
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+	case <-ctx.Done():
+		return nil, time.Time{}, ctx.Err()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fetch.err != nil {
+		if entry.items != nil && c.now().Sub(entry.fetchedAt) < c.Config.TTL+c.Config.StaleIfError {
+			slog.WarnContext(ctx, "Failed to refresh items, serving stale ones", "source", c.Source, "fetched_at", entry.fetchedAt, "error", fetch.err)
+			return firstItems(entry.items, maxItems), entry.fetchedAt, nil
+		}
+		return nil, time.Time{}, fetch.err
+	}
+	return firstItems(fetch.items, maxItems), fetch.fetchedAt, nil
+}
+
+// firstItems returns a copy of the first maxItems items.
+func firstItems(items []data.Item, maxItems int) []data.Item {
+	return slices.Clone(items[:max(0, min(maxItems, len(items)))])
+}
+
+// startFetch fetches maxItems items in the background, detached from the
+// cancellation of the request starting it, as other requests may wait for
+// them. The request deadline still applies. Fetched items are cached unless
+// a larger listing was cached meanwhile. Must be called with c.mu held.
+func (c *CachingRetriever) startFetch(ctx context.Context, maxItems int) *cacheFetch {
+	fetch := &cacheFetch{done: make(chan struct{}), maxItems: maxItems}
+	c.entry.fetch = fetch
+	fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if deadline, ok := ctx.Deadline(); ok {
+		fetchCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+	}
+	go func() {
+		defer cancel()
+		items, err := c.Retriever.GetItems(fetchCtx, maxItems)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err == nil && items == nil {
+			items = make([]data.Item, 0)
+		}
+		fetch.items, fetch.fetchedAt, fetch.err = items, c.now(), err
+		if err == nil && maxItems >= c.entry.maxItems {
+			c.entry.items, c.entry.fetchedAt, c.entry.maxItems = items, fetch.fetchedAt, maxItems
+		}
+		if c.entry.fetch == fetch {
+			c.entry.fetch = nil
+		}
+		close(fetch.done)
+	}()
+	return fetch
+}
+
+// Unwrap returns the cached Retriever.
+func (c *CachingRetriever) Unwrap() Retriever {
+	return c.Retriever
+}
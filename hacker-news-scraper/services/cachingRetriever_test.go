@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestCachingRetriever_GetItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sourceErr := errors.New("source down")
+	items := []data.Item{{Id: 1, Title: "First"}}
+	refreshed := []data.Item{{Id: 2, Title: "Refreshed"}}
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	var mu sync.Mutex
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewCachingRetriever("test", mockFetcher, CacheConfig{TTL: time.Minute, StaleWhileRevalidate: time.Minute, StaleIfError: 5 * time.Minute})
+	cache.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		fetches bool
+		items   []data.Item
+		err     error
+		// background flags fetches made after answering with stale items.
+		background bool
+		want       []data.Item
+		wantErr    error
+	}{
+		{name: "Missing items are fetched", fetches: true, items: items, want: items},
+		{name: "Fresh items are served from cache", advance: 30 * time.Second, want: items},
+		{name: "Stale items are served while revalidated", advance: 45 * time.Second, fetches: true, background: true, items: refreshed, want: items},
+		{name: "Revalidated items are served", want: refreshed},
+		{name: "Expired items are fetched", advance: 3 * time.Minute, fetches: true, items: items, want: items},
+		{name: "Failed fetch serves stale items", advance: 3 * time.Minute, fetches: true, err: sourceErr, want: items},
+		{name: "Failed fetch without stale items fails", advance: 4 * time.Minute, fetches: true, err: sourceErr, wantErr: sourceErr},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			mu.Lock()
+			now = now.Add(step.advance)
+			mu.Unlock()
+			fetched := make(chan struct{})
+			if step.fetches {
+				mockFetcher.EXPECT().GetItems(gomock.Any(), 10).DoAndReturn(func(context.Context, int) ([]data.Item, error) {
+					defer close(fetched)
+					return step.items, step.err
+				})
+			}
+			got, err := cache.GetItems(context.Background(), 10)
+			if !errors.Is(err, step.wantErr) {
+				t.Fatalf("GetItems() error = %v, want %v", err, step.wantErr)
+			}
+			if !reflect.DeepEqual(got, step.want) {
+				t.Errorf("GetItems() got = %v, want %v", got, step.want)
+			}
+			if step.background {
+				<-fetched
+				// Waits for the fetched items to be cached.
+				for cache.fetching() {
+					time.Sleep(time.Millisecond)
+				}
+			}
+		})
+	}
+}
+
+func (c *CachingRetriever) fetching() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entry.fetch != nil
+}
+
+func TestCachingRetriever_SingleFlight(t *testing.T) {
+	retriever := &countingRetriever{delay: 20 * time.Millisecond}
+	cache := NewCachingRetriever("test", retriever, DefaultCacheConfig)
+
+	var waitGroup sync.WaitGroup
+	for range 10 {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			if _, err := cache.GetItems(context.Background(), 10); err != nil {
+				t.Errorf("GetItems() error = %v", err)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	if got := retriever.fetches(); got != 1 {
+		t.Errorf("source fetched %d times, want 1", got)
+	}
+
+	if _, err := cache.GetItems(context.Background(), 20); err != nil {
+		t.Fatalf("GetItems() error = %v", err)
+	}
+	if got := retriever.fetches(); got != 2 {
+		t.Errorf("source fetched %d times, want a fetch of the larger listing", got)
+	}
+}
+
+func TestCachingRetriever_LargestListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	listing := make([]data.Item, 30)
+	for i := range listing {
+		listing[i] = data.Item{Id: data.ItemId(i + 1), Title: "Item"}
+	}
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	cache := NewCachingRetriever("test", mockFetcher, DefaultCacheConfig)
+
+	steps := []struct {
+		name     string
+		maxItems int
+		// fetched is the number of items fetched, none when served from cache.
+		fetched int
+	}{
+		{name: "Missing items are fetched", maxItems: 20, fetched: 20},
+		{name: "Fewer items are served from the listing", maxItems: 5},
+		{name: "As many items are served from the listing", maxItems: 20},
+		{name: "More items fetch a larger listing", maxItems: 30, fetched: 30},
+		{name: "Larger listing replaces the smaller one", maxItems: 25},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.fetched > 0 {
+				mockFetcher.EXPECT().GetItems(gomock.Any(), step.fetched).Return(listing[:step.fetched], nil)
+			}
+			got, err := cache.GetItems(context.Background(), step.maxItems)
+			if err != nil {
+				t.Fatalf("GetItems() error = %v", err)
+			}
+			if want := listing[:step.maxItems]; !reflect.DeepEqual(got, want) {
+				t.Errorf("GetItems() got %d items, want the first %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestCachingRetriever_CancelledRequest(t *testing.T) {
+	retriever := &countingRetriever{delay: 50 * time.Millisecond}
+	cache := NewCachingRetriever("test", retriever, DefaultCacheConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := cache.GetItems(ctx, 10); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetItems() error = %v, want %v", err, context.Canceled)
+	}
+	// The request waiting for the fetch it started leaves it running for others.
+	if _, err := cache.GetItems(context.Background(), 10); err != nil {
+		t.Fatalf("GetItems() error = %v", err)
+	}
+	if got := retriever.fetches(); got != 1 {
+		t.Errorf("source fetched %d times, want 1", got)
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit.
+	FailureThreshold int
+	// CoolDown is how long the circuit stays open before a probe is let through.
+	CoolDown time.Duration
+	// HalfOpenSuccesses is the number of successful probes closing the circuit again.
+	HalfOpenSuccesses int
+}
+
+var DefaultBreakerConfig = BreakerConfig{
+	FailureThreshold:  5,
+	CoolDown:          30 * time.Second,
+	HalfOpenSuccesses: 1,
+}
+
+type BreakerStatus struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+// CircuitBreaker wraps a Retriever and stops calling it after repeated
+// failures. Once the cool-down elapses the next call is let through as a
+// probe: its success closes the circuit again, its failure reopens it.
+type CircuitBreaker struct {
+	// Source names the guarded source in logs.
+	Source    string
+	Retriever Retriever
+	Config    BreakerConfig
+
+	now       func() time.Time
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	probing   bool
+	openedAt  time.Time
+	lastError string
+}
+
+func NewCircuitBreaker(source string, retriever Retriever, config BreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{Source: source, Retriever: retriever, Config: config, now: time.Now, state: BreakerClosed}
+}
+
+func (b *CircuitBreaker) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	items, err := b.Retriever.GetItems(ctx, maxItems)
+	b.record(ctx, probe, err)
+	return items, err
+}
+
+// breakerComments guards the comment fetches of a source with the circuit
+// breaker of its items. Stories not found are no failure of the source.
+type breakerComments struct {
+	breaker  *CircuitBreaker
+	comments CommentRetriever
+}
+
+func (b *CircuitBreaker) decorateComments(comments CommentRetriever) CommentRetriever {
+	return &breakerComments{breaker: b, comments: comments}
+}
+
+func (c *breakerComments) GetComments(ctx context.Context, storyId string, options CommentOptions) (data.CommentThread, error) {
+	probe, err := c.breaker.allow()
+	if err != nil {
+		return data.CommentThread{StoryId: storyId, Comments: make([]data.Comment, 0)}, err
+	}
+	thread, err := c.comments.GetComments(ctx, storyId, options)
+	if errors.Is(err, ErrStoryNotFound) {
+		c.breaker.record(ctx, probe, nil)
+	} else {
+		c.breaker.record(ctx, probe, err)
+	}
+	return thread, err
+}
+
+// Unwrap returns the guarded Retriever.
+func (b *CircuitBreaker) Unwrap() Retriever {
+	return b.Retriever
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := BreakerStatus{State: b.currentState(), ConsecutiveFailures: b.failures, LastError: b.lastError}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// currentState reports an open circuit whose cool-down elapsed as half-open.
+func (b *CircuitBreaker) currentState() BreakerState {
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.Config.CoolDown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a call may go through, and whether it is the probe
+// of a half-open circuit.
+func (b *CircuitBreaker) allow() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.currentState() {
+	case BreakerClosed:
+		return false, nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return false, ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true, nil
+	default:
+		return false, ErrCircuitOpen
+	}
+}
+
+// record updates the circuit with the outcome of a call let through by
+// allow. Only the probe decides whether a half-open circuit closes, calls
+// started before it was opened being no evidence of recovery.
+func (b *CircuitBreaker) record(ctx context.Context, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		// The caller went away: this says nothing about the source health.
+		return
+	case err != nil:
+		b.failures++
+		b.successes = 0
+		b.lastError = err.Error()
+		if probe || b.failures >= b.Config.FailureThreshold {
+			if b.state != BreakerOpen {
+				slog.WarnContext(ctx, "Opening circuit", "source", b.Source, "failures", b.failures, "error", err)
+			}
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+	case probe:
+		b.successes++
+		if b.successes >= b.Config.HalfOpenSuccesses {
+			slog.InfoContext(ctx, "Closing circuit", "source", b.Source, "probes", b.successes)
+			b.state = BreakerClosed
+			b.failures = 0
+			b.successes = 0
+		}
+	default:
+		b.failures = 0
+	}
+}
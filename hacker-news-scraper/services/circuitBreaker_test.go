@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCircuitBreaker_GetItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sourceErr := errors.New("source down")
+	items := []data.Item{{Id: 1, Title: "Item"}}
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker("test", mockFetcher, BreakerConfig{FailureThreshold: 2, CoolDown: time.Minute, HalfOpenSuccesses: 1})
+	breaker.now = func() time.Time { return now }
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		calls     bool
+		sourceErr error
+		wantErr   error
+		wantState BreakerState
+	}{
+		{name: "First failure keeps circuit closed", calls: true, sourceErr: sourceErr, wantErr: sourceErr, wantState: BreakerClosed},
+		{name: "Threshold failure opens circuit", calls: true, sourceErr: sourceErr, wantErr: sourceErr, wantState: BreakerOpen},
+		{name: "Open circuit skips source", calls: false, wantErr: ErrCircuitOpen, wantState: BreakerOpen},
+		{name: "Failed probe reopens circuit", advance: time.Minute, calls: true, sourceErr: sourceErr, wantErr: sourceErr, wantState: BreakerOpen},
+		{name: "Cool-down restarts after failed probe", advance: 30 * time.Second, calls: false, wantErr: ErrCircuitOpen, wantState: BreakerOpen},
+		{name: "Successful probe closes circuit", advance: 30 * time.Second, calls: true, wantState: BreakerClosed},
+		{name: "Closed circuit calls source", calls: true, wantState: BreakerClosed},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now = now.Add(step.advance)
+			if step.calls {
+				if step.sourceErr != nil {
+					mockFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(nil, step.sourceErr)
+				} else {
+					mockFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(items, nil)
+				}
+			}
+			_, err := breaker.GetItems(context.Background(), 10)
+			if !errors.Is(err, step.wantErr) {
+				t.Errorf("GetItems() error = %v, want %v", err, step.wantErr)
+			}
+			if got := breaker.Status().State; got != step.wantState {
+				t.Errorf("Status().State = %v, want %v", got, step.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_IgnoresCallerCancellation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	mockFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(nil, context.Canceled)
+	breaker := NewCircuitBreaker("test", mockFetcher, BreakerConfig{FailureThreshold: 1, CoolDown: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	breaker.GetItems(ctx, 10)
+	if got := breaker.Status(); got.State != BreakerClosed || got.ConsecutiveFailures != 0 {
+		t.Errorf("Status() = %+v, want closed circuit without failures", got)
+	}
+}
+
+func TestCircuitBreaker_StaleCallDuringProbe(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker("test", nil, BreakerConfig{FailureThreshold: 1, CoolDown: time.Minute, HalfOpenSuccesses: 1})
+	breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	stale, _ := breaker.allow()
+	failing, _ := breaker.allow()
+	breaker.record(ctx, failing, errors.New("source down"))
+	now = now.Add(time.Minute)
+	probe, err := breaker.allow()
+	if !probe || err != nil {
+		t.Fatalf("allow() = %v, %v, want the probe let through", probe, err)
+	}
+
+	// The call started before the circuit opened ends during the probe.
+	breaker.record(ctx, stale, nil)
+	if _, err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("allow() error = %v during the probe, want %v", err, ErrCircuitOpen)
+	}
+	if got := breaker.Status().State; got != BreakerHalfOpen {
+		t.Errorf("Status().State = %v after a stale call, want %v", got, BreakerHalfOpen)
+	}
+	breaker.record(ctx, probe, nil)
+	if got := breaker.Status().State; got != BreakerClosed {
+		t.Errorf("Status().State = %v after the probe, want %v", got, BreakerClosed)
+	}
+}
+
+// commentsStub is a source answering every comment fetch with err.
+type commentsStub struct {
+	calls int
+	err   error
+}
+
+func (s *commentsStub) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	return nil, nil
+}
+
+func (s *commentsStub) GetComments(ctx context.Context, storyId string, options CommentOptions) (data.CommentThread, error) {
+	s.calls++
+	return data.CommentThread{StoryId: storyId}, s.err
+}
+
+func TestCircuitBreaker_GetComments(t *testing.T) {
+	source := &commentsStub{}
+	breaker := NewCircuitBreaker("test", NewInstrumentedRetriever("comments-test", source), BreakerConfig{FailureThreshold: 2, CoolDown: time.Minute, HalfOpenSuccesses: 1})
+	comments, ok := CommentsOf(breaker)
+	if !ok {
+		t.Fatalf("CommentsOf() found no comments behind the breaker")
+	}
+	errorsBefore := testutil.ToFloat64(sourceCommentErrors.WithLabelValues("comments-test", ErrorKindStatus))
+
+	steps := []struct {
+		name      string
+		sourceErr error
+		wantCalls int
+		wantErr   error
+		wantState BreakerState
+	}{
+		{name: "Missing stories keep the circuit closed", sourceErr: ErrStoryNotFound, wantCalls: 1, wantErr: ErrStoryNotFound, wantState: BreakerClosed},
+		{name: "Failure below threshold", sourceErr: StatusError{StatusCode: 503}, wantCalls: 2, wantErr: StatusError{StatusCode: 503}, wantState: BreakerClosed},
+		{name: "Failure opening circuit", sourceErr: StatusError{StatusCode: 503}, wantCalls: 3, wantErr: StatusError{StatusCode: 503}, wantState: BreakerOpen},
+		{name: "Open circuit skips source", wantCalls: 3, wantErr: ErrCircuitOpen, wantState: BreakerOpen},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			source.err = step.sourceErr
+			if _, err := comments.GetComments(context.Background(), "1", CommentOptions{}); !errors.Is(err, step.wantErr) {
+				t.Errorf("GetComments() error = %v, want %v", err, step.wantErr)
+			}
+			if source.calls != step.wantCalls {
+				t.Errorf("source called %d times, want %d", source.calls, step.wantCalls)
+			}
+			if state := breaker.Status().State; state != step.wantState {
+				t.Errorf("breaker state = %v, want %v", state, step.wantState)
+			}
+		})
+	}
+	if got := testutil.ToFloat64(sourceCommentErrors.WithLabelValues("comments-test", ErrorKindStatus)) - errorsBefore; got != 2 {
+		t.Errorf("comment errors counted %v times, want 2", got)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+var ErrStoryNotFound = errors.New("story not found")
+
+type CommentOptions struct {
+	// MaxDepth is the number of comment levels retrieved, 1 meaning top level comments only.
+	MaxDepth int
+	// MaxComments bounds the number of comments of the thread.
+	MaxComments int
+}
+
+// CommentRetriever fetches the comment thread of a story of a source.
+type CommentRetriever interface {
+	GetComments(ctx context.Context, storyId string, options CommentOptions) (data.CommentThread, error)
+}
+
+// commentsDecorator is implemented by the decorators applying to the comment
+// fetches of the retriever they wrap too.
+type commentsDecorator interface {
+	decorateComments(comments CommentRetriever) CommentRetriever
+}
+
+// CommentsOf returns the CommentRetriever behind retriever, looking through
+// the decorators wrapping a connector. Comments are fetched through the
+// decorators applying to them, such as CircuitBreaker.
+func CommentsOf(retriever Retriever) (CommentRetriever, bool) {
+	var decorators []commentsDecorator
+	for retriever != nil {
+		if comments, ok := retriever.(CommentRetriever); ok {
+			for i := len(decorators) - 1; i >= 0; i-- {
+				comments = decorators[i].decorateComments(comments)
+			}
+			return comments, true
+		}
+		if decorator, ok := retriever.(commentsDecorator); ok {
+			decorators = append(decorators, decorator)
+		}
+		wrapper, ok := retriever.(interface{ Unwrap() Retriever })
+		if !ok {
+			break
+		}
+		retriever = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
+// RetrieverAs returns the first retriever of type T found unwrapping the
+// decorators chain starting at retriever, such as the CircuitBreaker of a
+// polled source.
+func RetrieverAs[T any](retriever Retriever) (T, bool) {
+	for retriever != nil {
+		if target, ok := retriever.(T); ok {
+			return target, true
+		}
+		wrapper, ok := retriever.(interface{ Unwrap() Retriever })
+		if !ok {
+			break
+		}
+		retriever = wrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// commentBudget counts the comments of a thread against its size limit.
+type commentBudget struct {
+	remaining atomic.Int64
+	truncated atomic.Bool
+}
+
+func newCommentBudget(maxComments int) *commentBudget {
+	budget := &commentBudget{}
+	budget.remaining.Store(int64(maxComments))
+	return budget
+}
+
+// take reserves a comment, flagging the thread as truncated when exhausted.
+func (b *commentBudget) take() bool {
+	if b.remaining.Add(-1) < 0 {
+		b.truncated.Store(true)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+// minTitleSimilarity is the share of title words two stories from different
+// sources must have in common to be considered the same story.
+const minTitleSimilarity = 0.8
+
+// minSimilarTitleWords avoids merging stories on short generic titles.
+const minSimilarTitleWords = 3
+
+var trackingParams = map[string]bool{"fbclid": true, "gclid": true, "ref": true, "ref_src": true, "mc_cid": true, "mc_eid": true}
+
+// CanonicalURL normalizes a story URL so that the same article linked from
+// different sources compares equal: scheme, "www." prefix, default ports,
+// trailing slashes, fragments and tracking parameters are dropped.
+func CanonicalURL(rawUrl string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	if port := parsed.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	query := parsed.Query()
+	for param := range query {
+		if strings.HasPrefix(strings.ToLower(param), "utm_") || trackingParams[strings.ToLower(param)] {
+			query.Del(param)
+		}
+	}
+	canonical := host + strings.TrimRight(parsed.EscapedPath(), "/")
+	if encodedQuery := query.Encode(); encodedQuery != "" {
+		canonical += "?" + encodedQuery
+	}
+	return canonical
+}
+
+// deduplicate merges the items linking the same story, or titled alike when
+// one of them has no link, keeping the first occurrence and adding the
+// sources of the others, whose own score and comments are only reported in
+// Sources. sites holds the site of every item: items of the same site and
+// id, listed by several lists of a site, are the same item and only the
+// first one is kept.
+func deduplicate(items []data.Item, sites []string) []data.Item {
+	merged := make([]data.Item, 0, len(items))
+	canonicalUrls := make([]string, 0, len(items))
+	titlesWords := make([][]string, 0, len(items))
+	listed := make(map[string]bool, len(items))
+	for i, item := range items {
+		identity := sites[i] + "/" + ItemId(item)
+		if listed[identity] {
+			continue
+		}
+		listed[identity] = true
+		canonicalUrl := CanonicalURL(item.Url)
+		titleWords := normalizedWords(item.Title)
+		duplicateOf := -1
+		for j := range merged {
+			// Titles are only compared when a URL is missing, different links
+			// being different stories however alike their titles.
+			if canonicalUrl != "" && canonicalUrl == canonicalUrls[j] ||
+				(canonicalUrl == "" || canonicalUrls[j] == "") && !sharesSource(merged[j], item) && titleSimilarity(titleWords, titlesWords[j]) >= minTitleSimilarity {
+				duplicateOf = j
+				break
+			}
+		}
+		if duplicateOf < 0 {
+			merged = append(merged, item)
+			canonicalUrls = append(canonicalUrls, canonicalUrl)
+			titlesWords = append(titlesWords, titleWords)
+			continue
+		}
+		original := &merged[duplicateOf]
+		original.Sources = append(original.Sources, item.Sources...)
+		if original.Url == "" {
+			original.Url = item.Url
+			canonicalUrls[duplicateOf] = canonicalUrl
+		}
+	}
+	return merged
+}
+
+func sharesSource(a, b data.Item) bool {
+	for _, sourceA := range a.Sources {
+		for _, sourceB := range b.Sources {
+			if sourceA.Source == sourceB.Source {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalizedWords(title string) []string {
+	return strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// titleSimilarity returns the Jaccard index of two sets of title words.
+func titleSimilarity(a, b []string) float64 {
+	if len(a) < minSimilarTitleWords || len(b) < minSimilarTitleWords {
+		return 0
+	}
+	wordsA := make(map[string]bool, len(a))
+	for _, word := range a {
+		wordsA[word] = true
+	}
+	wordsB := make(map[string]bool, len(b))
+	common := 0
+	for _, word := range b {
+		if !wordsB[word] && wordsA[word] {
+			common++
+		}
+		wordsB[word] = true
+	}
+	return float64(common) / float64(len(wordsA)+len(wordsB)-common)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "Plain URL", url: "https://example.com/post", want: "example.com/post"},
+		{name: "Scheme, www and trailing slash", url: "http://www.Example.com/post/", want: "example.com/post"},
+		{name: "Tracking parameters", url: "https://example.com/post?utm_source=hn&id=3&utm_medium=social&ref=lobsters", want: "example.com/post?id=3"},
+		{name: "Fragment and default port", url: "https://example.com:443/post#comments", want: "example.com/post"},
+		{name: "Non default port", url: "https://example.com:8443/post", want: "example.com:8443/post"},
+		{name: "Empty URL", url: "", want: ""},
+		{name: "Relative URL", url: "/s/abc123", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalURL(tt.url); got != tt.want {
+				t.Errorf("CanonicalURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregator_GetItemsDeduplicate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hnItems := []data.Item{
+		{Id: 1, Title: "Stupid Slow: The Perceived Speed of Computers", Url: "https://www.datagubbe.se/stupidslow/", Score: 100, Descendants: 40},
+		{Id: 2, Title: "XScreenSaver: Google Store Privacy Policy", Url: "https://www.jwz.org/xscreensaver/google.html", Score: 50, Descendants: 10},
+		{Id: 3, Title: "Only on Hacker News", Url: "https://example.com/hn", Score: 10, Descendants: 1},
+	}
+	lobstersItems := []data.Item{
+		{Id: 11, Title: "Stupid slow: the perceived speed of computers", Url: "http://datagubbe.se/stupidslow?utm_source=lobsters", Score: 21, Descendants: 4},
+		{Id: 12, Title: "XScreenSaver: Google Store privacy policy", Score: 104, Descendants: 10},
+		{Id: 13, Title: "Only on Hacker News", Url: "https://example.org/lobsters", Score: 5, Descendants: 2},
+	}
+	hnFetcher := mock_services.NewMockRetriever(ctrl)
+	hnFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(hnItems, nil)
+	lobstersFetcher := mock_services.NewMockRetriever(ctrl)
+	lobstersFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(lobstersItems, nil)
+	// Another list of Hacker News lists the same stories.
+	hnBestFetcher := mock_services.NewMockRetriever(ctrl)
+	hnBestFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(hnItems[:1], nil)
+
+	agg := &Aggregator{
+		Connectors: []SourceConnectors{{SourceName: "hn", Connector: hnFetcher, Site: "news.ycombinator.com"}, {SourceName: "lobsters", Connector: lobstersFetcher},
+			{SourceName: "hn-best", Connector: hnBestFetcher, Site: "news.ycombinator.com"}},
+		Ranker:      ScoreRanker{},
+		Deduplicate: true,
+	}
+	got, err := agg.GetItems(context.Background(), 9)
+	if err != nil {
+		t.Fatalf("GetItems() error = %v", err)
+	}
+	// Merged items keep the score and comments of their first source.
+	want := []data.Item{
+		{Id: 1, Title: hnItems[0].Title, Url: hnItems[0].Url, Score: 100, Descendants: 40, Sources: []data.ItemSource{
+			{Source: "hn", Id: 1, Url: hnItems[0].Url, Score: 100, Comments: 40},
+			{Source: "lobsters", Id: 11, Url: lobstersItems[0].Url, Score: 21, Comments: 4},
+		}},
+		{Id: 2, Title: hnItems[1].Title, Url: hnItems[1].Url, Score: 50, Descendants: 10, Sources: []data.ItemSource{
+			{Source: "hn", Id: 2, Url: hnItems[1].Url, Score: 50, Comments: 10},
+			{Source: "lobsters", Id: 12, Score: 104, Comments: 10},
+		}},
+		{Id: 3, Title: hnItems[2].Title, Url: hnItems[2].Url, Score: 10, Descendants: 1, Sources: []data.ItemSource{
+			{Source: "hn", Id: 3, Url: hnItems[2].Url, Score: 10, Comments: 1},
+		}},
+		// Alike titles linking different stories are kept apart.
+		{Id: 13, Title: lobstersItems[2].Title, Url: lobstersItems[2].Url, Score: 5, Descendants: 2, Sources: []data.ItemSource{
+			{Source: "lobsters", Id: 13, Url: lobstersItems[2].Url, Score: 5, Comments: 2},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetItems() got = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+const maxFeedSize = 10 << 20
+
+// ErrFeedTooLarge reports a feed document larger than maxFeedSize, which is
+// not read further.
+var ErrFeedTooLarge = fmt.Errorf("feed too large, over %d bytes", maxFeedSize)
+
+// rssDateLayouts lists the date formats found in the wild in RSS pubDate
+// elements, RFC 822 being the one mandated by the specification.
+var rssDateLayouts = []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2 Jan 2006 15:04:05 -0700", time.RFC3339}
+
+// FeedConnector retrieves the entries of an RSS 2.0, Atom 1.0 or JSON Feed 1.1
+// document, detecting the format from the document itself.
+type FeedConnector struct {
+	Url string
+	// Client is used for every request. http.DefaultClient is used when nil.
+	Client *http.Client
+}
+
+type rssFeed struct {
+	Items []rssItem `xml:"channel>item"`
+}
+
+type rssItem struct {
+	Title      string   `xml:"title"`
+	Link       string   `xml:"link"`
+	Guid       string   `xml:"guid"`
+	Author     string   `xml:"author"`
+	Creator    string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	PubDate    string   `xml:"pubDate"`
+	Comments   int      `xml:"http://purl.org/rss/1.0/modules/slash/ comments"`
+	Categories []string `xml:"category"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type atomEntry struct {
+	Id        string `xml:"id"`
+	Title     string `xml:"title"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+	Comments int `xml:"http://purl.org/rss/1.0/modules/slash/ comments"`
+}
+
+type jsonFeed struct {
+	Items []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	Id            string           `json:"id"`
+	Url           string           `json:"url"`
+	ExternalUrl   string           `json:"external_url"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary"`
+	DatePublished string           `json:"date_published"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	// Author is the JSON Feed 1.0 single author, replaced by Authors in 1.1.
+	Author *jsonFeedAuthor `json:"author"`
+	Tags   []string        `json:"tags"`
+}
+
+func (c *FeedConnector) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/feed+json, application/atom+xml, application/rss+xml, application/xml;q=0.9, */*;q=0.8")
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to make request", "url", c.Url, "error", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.WarnContext(ctx, "Failed to make request", "url", c.Url, "status", resp.Status)
+		return nil, StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read response body", "url", c.Url, "error", err)
+		return nil, err
+	}
+	if len(body) > maxFeedSize {
+		slog.WarnContext(ctx, "Feed too large", "url", c.Url, "max_size", maxFeedSize)
+		return nil, ErrFeedTooLarge
+	}
+
+	items, err := parseFeed(body)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to parse feed", "url", c.Url, "error", err)
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w in feed", ErrNoItems)
+	}
+	return items[:min(len(items), maxItems)], nil
+}
+
+func parseFeed(body []byte) ([]data.Item, error) {
+	body = bytes.TrimSpace(body)
+	if bytes.HasPrefix(body, []byte("{")) {
+		return parseJSONFeed(body)
+	}
+
+	root, err := feedRootElement(body)
+	if err != nil {
+		return nil, err
+	}
+	switch root {
+	case "rss":
+		return parseRSS(body)
+	case "feed":
+		return parseAtom(body)
+	default:
+		return nil, itemError("unsupported feed format <" + root + ">")
+	}
+}
+
+func feedRootElement(body []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func parseRSS(body []byte) ([]data.Item, error) {
+	var feed rssFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	items := make([]data.Item, len(feed.Items))
+	for i, entry := range feed.Items {
+		author := entry.Creator
+		if author == "" {
+			author = entry.Author
+		}
+		items[i] = feedItem(firstNonEmpty(entry.Guid, entry.Link, entry.Title), entry.Title, entry.Link, author, parseFeedTime(entry.PubDate, rssDateLayouts), entry.Comments, entry.Categories)
+	}
+	return items, nil
+}
+
+func parseAtom(body []byte) ([]data.Item, error) {
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	items := make([]data.Item, len(feed.Entries))
+	for i, entry := range feed.Entries {
+		link := ""
+		for _, entryLink := range entry.Links {
+			if entryLink.Rel == "" || entryLink.Rel == "alternate" {
+				link = entryLink.Href
+				break
+			}
+		}
+		author := ""
+		if len(entry.Authors) > 0 {
+			author = entry.Authors[0].Name
+		}
+		tags := make([]string, len(entry.Categories))
+		for j, category := range entry.Categories {
+			tags[j] = category.Term
+		}
+		published := parseFeedTime(firstNonEmpty(entry.Published, entry.Updated), []string{time.RFC3339})
+		items[i] = feedItem(firstNonEmpty(entry.Id, link, entry.Title), entry.Title, link, author, published, entry.Comments, tags)
+	}
+	return items, nil
+}
+
+func parseJSONFeed(body []byte) ([]data.Item, error) {
+	var feed jsonFeed
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	items := make([]data.Item, len(feed.Items))
+	for i, entry := range feed.Items {
+		author := ""
+		if len(entry.Authors) > 0 {
+			author = entry.Authors[0].Name
+		} else if entry.Author != nil {
+			author = entry.Author.Name
+		}
+		link := firstNonEmpty(entry.ExternalUrl, entry.Url)
+		items[i] = feedItem(firstNonEmpty(entry.Id, link), firstNonEmpty(entry.Title, entry.Summary), link, author, parseFeedTime(entry.DatePublished, []string{time.RFC3339}), 0, entry.Tags)
+	}
+	return items, nil
+}
+
+// feedItem builds an item whose id is derived from the entry unique key, as
+// feeds do not provide numeric identifiers.
+func feedItem(key, title, link, author string, published time.Time, comments int, tags []string) data.Item {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	item := data.Item{
+		By:          strings.TrimSpace(author),
+		Descendants: comments,
+		Id:          data.ItemId(hash.Sum64() >> 1),
+		Title:       strings.TrimSpace(title),
+		Type:        "story",
+		Url:         strings.TrimSpace(link),
+	}
+	if !published.IsZero() {
+		item.Time = int(published.Unix())
+	}
+	if len(tags) > 0 {
+		item.Tags = tags
+	}
+	return item
+}
+
+func parseFeedTime(value string, layouts []string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+const mockRSSFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:slash="http://purl.org/rss/1.0/modules/slash/">
+<channel>
+<title>Test blog</title>
+<item>
+<title>First post</title>
+<link>https://blog.test/first</link>
+<guid>https://blog.test/?p=1</guid>
+<dc:creator>alice</dc:creator>
+<pubDate>Sat, 01 Jun 2024 12:00:00 +0000</pubDate>
+<comments>https://blog.test/first#comments</comments>
+<slash:comments>7</slash:comments>
+<category>go</category>
+</item>
+<item>
+<title>Second post</title>
+<link>https://blog.test/second</link>
+<author>bob@blog.test (Bob)</author>
+<pubDate>Sun, 2 Jun 2024 08:30:00 GMT</pubDate>
+</item>
+</channel>
+</rss>`
+
+const mockAtomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>Test releases</title>
+<entry>
+<id>tag:releases.test,2024:v1.2.0</id>
+<title>v1.2.0</title>
+<link rel="replies" href="https://releases.test/v1.2.0/comments"/>
+<link rel="alternate" href="https://releases.test/v1.2.0"/>
+<author><name>carol</name></author>
+<published>2024-06-01T12:00:00Z</published>
+<updated>2024-06-02T12:00:00Z</updated>
+<category term="release"/>
+</entry>
+<entry>
+<id>tag:releases.test,2024:v1.1.0</id>
+<title>v1.1.0</title>
+<link href="https://releases.test/v1.1.0"/>
+<updated>2024-05-01T12:00:00Z</updated>
+</entry>
+</feed>`
+
+const mockJSONFeed = `{"version":"https://jsonfeed.org/version/1.1","title":"Test listing","items":[
+{"id":"2406.00001","url":"https://papers.test/abs/2406.00001","title":"A paper","date_published":"2024-06-01T12:00:00Z","authors":[{"name":"dave"}],"tags":["cs.DC"]},
+{"id":"2406.00002","url":"https://papers.test/abs/2406.00002","external_url":"https://code.test/repo","summary":"Untitled paper summary","author":{"name":"erin"}}]}`
+
+func TestFeedConnector_GetItems(t *testing.T) {
+	mux := http.NewServeMux()
+	for path, body := range map[string]string{"/rss": mockRSSFeed, "/atom": mockAtomFeed, "/json": mockJSONFeed, "/html": "<html><body>Not a feed</body></html>", "/empty": `{"version":"https://jsonfeed.org/version/1.1","items":[]}`} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	rssItem1 := feedItemForTest("https://blog.test/?p=1", data.Item{By: "alice", Descendants: 7, Time: 1717243200, Title: "First post", Type: "story", Url: "https://blog.test/first", Tags: []string{"go"}})
+	rssItem2 := feedItemForTest("https://blog.test/second", data.Item{By: "bob@blog.test (Bob)", Time: 1717317000, Title: "Second post", Type: "story", Url: "https://blog.test/second"})
+	atomItem1 := feedItemForTest("tag:releases.test,2024:v1.2.0", data.Item{By: "carol", Time: 1717243200, Title: "v1.2.0", Type: "story", Url: "https://releases.test/v1.2.0", Tags: []string{"release"}})
+	atomItem2 := feedItemForTest("tag:releases.test,2024:v1.1.0", data.Item{Time: 1714564800, Title: "v1.1.0", Type: "story", Url: "https://releases.test/v1.1.0"})
+	jsonItem1 := feedItemForTest("2406.00001", data.Item{By: "dave", Time: 1717243200, Title: "A paper", Type: "story", Url: "https://papers.test/abs/2406.00001", Tags: []string{"cs.DC"}})
+	jsonItem2 := feedItemForTest("2406.00002", data.Item{By: "erin", Title: "Untitled paper summary", Type: "story", Url: "https://code.test/repo"})
+	tests := []struct {
+		name     string
+		path     string
+		maxItems int
+		want     []data.Item
+		wantErr  bool
+	}{
+		{name: "RSS 2.0", path: "/rss", maxItems: 10, want: []data.Item{rssItem1, rssItem2}},
+		{name: "Atom 1.0", path: "/atom", maxItems: 10, want: []data.Item{atomItem1, atomItem2}},
+		{name: "JSON Feed", path: "/json", maxItems: 10, want: []data.Item{jsonItem1, jsonItem2}},
+		{name: "Limited items", path: "/rss", maxItems: 1, want: []data.Item{rssItem1}},
+		{name: "Unsupported document", path: "/html", maxItems: 10, wantErr: true},
+		{name: "Empty feed", path: "/empty", maxItems: 10, wantErr: true},
+		{name: "Error in remote server", path: "/missing", maxItems: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &FeedConnector{Url: ts.URL + tt.path, Client: ts.Client()}
+			got, err := c.GetItems(context.Background(), tt.maxItems)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetItems() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetItems() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedConnector_GetItemsTooLarge(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mockRSSFeed))
+		w.Write(bytes.Repeat([]byte(" "), maxFeedSize))
+	}))
+	defer ts.Close()
+
+	c := &FeedConnector{Url: ts.URL, Client: ts.Client()}
+	if got, err := c.GetItems(context.Background(), 10); !errors.Is(err, ErrFeedTooLarge) {
+		t.Errorf("GetItems() got = %v, %v, want error %v", got, err, ErrFeedTooLarge)
+	}
+}
+
+func feedItemForTest(key string, item data.Item) data.Item {
+	item.Id = feedItem(key, "", "", "", time.Time{}, 0, nil).Id
+	return item
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+)
+
+type HTTPClientConfig struct {
+	// Timeout bounds a whole request, body included.
+	Timeout             time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+}
+
+var DefaultHTTPClientConfig = HTTPClientConfig{
+	Timeout:             15 * time.Second,
+	DialTimeout:         5 * time.Second,
+	TLSHandshakeTimeout: 5 * time.Second,
+	IdleConnTimeout:     90 * time.Second,
+	MaxIdleConnsPerHost: 16,
+	MaxConnsPerHost:     16,
+}
+
+// NewHTTPClient builds a client with its own transport, so connections to a
+// source are pooled and capped independently of http.DefaultClient. Its
+// requests are traced.
+func NewHTTPClient(config HTTPClientConfig) *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: config.TLSHandshakeTimeout,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		MaxIdleConns:        config.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.MaxConnsPerHost,
+	}
+	return &http.Client{Transport: TracedTransport(transport), Timeout: config.Timeout}
+}
+
+// StatusError reports an unexpected response status from a source.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e StatusError) Error() string { return "Response status: " + e.Status }
+
+// fetchJSON decodes into target the JSON document served at reqUrl, failing
+// on any status other than 200. http.DefaultClient is used when client is nil.
+func fetchJSON(ctx context.Context, client *http.Client, reqUrl string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
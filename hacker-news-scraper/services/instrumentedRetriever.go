@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentedRetriever is a Retriever decorator measuring every fetch of a
+// source: its latency, the number of items returned and its errors by kind.
+// Fetches succeeding without any item fail with ErrNoItems, counted as empty
+// errors. Every fetch is traced as a span, and the outcome of the last ones
+// is kept to be reported without fetching the source again.
+type InstrumentedRetriever struct {
+	Source    string
+	Retriever Retriever
+
+	mu        sync.Mutex
+	lastFetch FetchStatus
+}
+
+// FetchStatus holds the outcome of the last fetches of a source.
+type FetchStatus struct {
+	LastSuccess time.Time
+	LastFailure time.Time
+	// LastError is the error of the last failed fetch.
+	LastError string
+	// Latency is the time taken by the last fetch.
+	Latency time.Duration
+	// Items is the number of items returned by the last successful fetch.
+	Items int
+}
+
+func NewInstrumentedRetriever(source string, retriever Retriever) *InstrumentedRetriever {
+	return &InstrumentedRetriever{Source: source, Retriever: retriever}
+}
+
+func (r *InstrumentedRetriever) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	ctx, span := tracer.Start(ctx, "Retriever.GetItems", trace.WithAttributes(sourceAttribute(r.Source), attribute.Int("scraper.max_items", maxItems)))
+	start := time.Now()
+	items, err := r.Retriever.GetItems(ctx, maxItems)
+	span.SetAttributes(attribute.Int("scraper.items", len(items)))
+	defer func() { endSpan(span, err) }()
+	latency := time.Since(start)
+	sourceFetchDuration.WithLabelValues(r.Source).Observe(latency.Seconds())
+	switch {
+	case err != nil && ctx.Err() == context.Canceled:
+		// Fetches abandoned by their caller are not the source's fault.
+		return items, err
+	case err != nil:
+		sourceErrors.WithLabelValues(r.Source, ErrorKind(err)).Inc()
+	case len(items) == 0 && maxItems > 0:
+		err = ErrNoItems
+		sourceErrors.WithLabelValues(r.Source, ErrorKindEmpty).Inc()
+		sourceItems.WithLabelValues(r.Source).Set(0)
+	default:
+		sourceItems.WithLabelValues(r.Source).Set(float64(len(items)))
+		sourceItemsTotal.WithLabelValues(r.Source).Add(float64(len(items)))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastFetch.Latency = latency
+	if err != nil {
+		r.lastFetch.LastFailure, r.lastFetch.LastError = start.Add(latency), err.Error()
+	} else {
+		r.lastFetch.LastSuccess, r.lastFetch.Items = start.Add(latency), len(items)
+	}
+	return items, err
+}
+
+// instrumentedComments measures the comment fetches of a source apart from
+// the fetches of its items, stories not found being no error.
+type instrumentedComments struct {
+	source   string
+	comments CommentRetriever
+}
+
+func (r *InstrumentedRetriever) decorateComments(comments CommentRetriever) CommentRetriever {
+	return &instrumentedComments{source: r.Source, comments: comments}
+}
+
+func (c *instrumentedComments) GetComments(ctx context.Context, storyId string, options CommentOptions) (data.CommentThread, error) {
+	ctx, span := tracer.Start(ctx, "CommentRetriever.GetComments", trace.WithAttributes(sourceAttribute(c.source), attribute.String("scraper.story_id", storyId)))
+	start := time.Now()
+	thread, err := c.comments.GetComments(ctx, storyId, options)
+	defer endSpan(span, err)
+	sourceCommentsDuration.WithLabelValues(c.source).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, ErrStoryNotFound) && ctx.Err() != context.Canceled {
+		sourceCommentErrors.WithLabelValues(c.source, ErrorKind(err)).Inc()
+	}
+	return thread, err
+}
+
+// LastFetch returns the outcome of the last fetches of the source.
+func (r *InstrumentedRetriever) LastFetch() FetchStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastFetch
+}
+
+// Unwrap returns the decorated retriever.
+func (r *InstrumentedRetriever) Unwrap() Retriever {
+	return r.Retriever
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentedRetriever_GetItems(t *testing.T) {
+	items := []data.Item{{Id: 1, Title: "First"}, {Id: 2, Title: "Second"}}
+	tests := []struct {
+		name      string
+		items     []data.Item
+		err       error
+		cancel    bool
+		wantItems float64
+		wantKind  string
+	}{
+		{name: "Items", items: items, wantItems: 2},
+		{name: "No items", items: []data.Item{}, wantKind: ErrorKindEmpty},
+		{name: "Empty scrape", err: fmt.Errorf("%w in scrapping", ErrNoItems), wantKind: ErrorKindEmpty},
+		{name: "Network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, wantKind: ErrorKindNetwork},
+		{name: "Timeout", err: fmt.Errorf("lobsters: %w", context.DeadlineExceeded), wantKind: ErrorKindTimeout},
+		{name: "Status", err: StatusError{StatusCode: 503, Status: "503 Service Unavailable"}, wantKind: ErrorKindStatus},
+		{name: "Decode error", err: json.Unmarshal([]byte("{"), &items), wantKind: ErrorKindDecode},
+		{name: "Other error", err: itemError("There has been an error getting some item"), wantKind: ErrorKindOther},
+		{name: "Cancelled fetch", err: context.Canceled, cancel: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			source := "instrumented " + tt.name
+			fetcher := mock_services.NewMockRetriever(ctrl)
+			fetcher.EXPECT().GetItems(gomock.Any(), 10).Return(tt.items, tt.err)
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			retriever := NewInstrumentedRetriever(source, fetcher)
+			_, err := retriever.GetItems(ctx, 10)
+			if got := testutil.CollectAndCount(sourceFetchDuration, "scraper_source_fetch_duration_seconds"); got == 0 {
+				t.Errorf("fetch duration was not observed")
+			}
+			if got := testutil.ToFloat64(sourceItems.WithLabelValues(source)); got != tt.wantItems {
+				t.Errorf("scraper_source_items = %v, want %v", got, tt.wantItems)
+			}
+			if got := testutil.ToFloat64(sourceItemsTotal.WithLabelValues(source)); got != tt.wantItems {
+				t.Errorf("scraper_source_items_total = %v, want %v", got, tt.wantItems)
+			}
+			for _, kind := range []string{ErrorKindNetwork, ErrorKindTimeout, ErrorKindStatus, ErrorKindDecode, ErrorKindEmpty, ErrorKindOther} {
+				want := 0.0
+				if kind == tt.wantKind {
+					want = 1
+				}
+				if got := testutil.ToFloat64(sourceErrors.WithLabelValues(source, kind)); got != want {
+					t.Errorf("scraper_source_errors_total of kind %s = %v, want %v", kind, got, want)
+				}
+			}
+			wantErr := tt.err
+			if tt.err == nil && len(tt.items) == 0 {
+				wantErr = ErrNoItems
+			}
+			if !errors.Is(err, wantErr) {
+				t.Errorf("GetItems() error = %v, want %v", err, wantErr)
+			}
+			lastFetch := retriever.LastFetch()
+			wantFailure, wantSuccess := wantErr != nil && !tt.cancel, wantErr == nil
+			if !lastFetch.LastFailure.IsZero() != wantFailure || !lastFetch.LastSuccess.IsZero() != wantSuccess {
+				t.Errorf("LastFetch() = %+v, want failure %v and success %v", lastFetch, wantFailure, wantSuccess)
+			}
+			if wantFailure && lastFetch.LastError != wantErr.Error() {
+				t.Errorf("LastFetch() error = %q, want %q", lastFetch.LastError, wantErr.Error())
+			}
+			if wantErr == nil && lastFetch.Items != int(tt.wantItems) {
+				t.Errorf("LastFetch() items = %d, want %v", lastFetch.Items, tt.wantItems)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+// ItemFilter selects items by their content. Empty fields match every item.
+type ItemFilter struct {
+	// Keywords match items whose title contains any of them, ignoring case.
+	Keywords []string
+	// Pattern matches items whose title it matches.
+	Pattern     *regexp.Regexp
+	MinScore    int
+	MinComments int
+	// Domains match items linking to any of them or to their subdomains.
+	Domains []string
+	// ExcludedDomains leave out items linking to any of them or to their
+	// subdomains.
+	ExcludedDomains []string
+	// Authors match items submitted by any of them, ignoring case.
+	Authors []string
+	// Types match items of any of them, such as "story" or "job".
+	Types []string
+	// Since and Until bound the submission time of the items.
+	Since time.Time
+	Until time.Time
+}
+
+func (f ItemFilter) Matches(item data.Item) bool {
+	if item.Score < f.MinScore || item.Descendants < f.MinComments {
+		return false
+	}
+	if len(f.Keywords) > 0 {
+		title := strings.ToLower(item.Title)
+		found := false
+		for _, keyword := range f.Keywords {
+			found = found || strings.Contains(title, strings.ToLower(keyword))
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Pattern != nil && !f.Pattern.MatchString(item.Title) {
+		return false
+	}
+	if len(f.Domains) > 0 && !inDomains(ItemDomain(item), f.Domains) {
+		return false
+	}
+	if len(f.ExcludedDomains) > 0 && inDomains(ItemDomain(item), f.ExcludedDomains) {
+		return false
+	}
+	if len(f.Authors) > 0 && !slices.ContainsFunc(f.Authors, func(author string) bool { return strings.EqualFold(author, item.By) }) {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, item.Type) {
+		return false
+	}
+	submitted := time.Unix(int64(item.Time), 0)
+	if !f.Since.IsZero() && submitted.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && submitted.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// IsEmpty reports whether f matches every item.
+func (f ItemFilter) IsEmpty() bool {
+	return len(f.Keywords) == 0 && f.Pattern == nil && f.MinScore <= 0 && f.MinComments <= 0 && len(f.Domains) == 0 &&
+		len(f.ExcludedDomains) == 0 && len(f.Authors) == 0 && len(f.Types) == 0 && f.Since.IsZero() && f.Until.IsZero()
+}
+
+// Apply returns the items matching f, keeping their order.
+func (f ItemFilter) Apply(items []data.Item) []data.Item {
+	matching := make([]data.Item, 0, len(items))
+	for _, item := range items {
+		if f.Matches(item) {
+			matching = append(matching, item)
+		}
+	}
+	return matching
+}
+
+func inDomains(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(domain), "www.")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ItemDomain returns the host an item links to, without "www." prefix.
+func ItemDomain(item data.Item) string {
+	parsed, err := url.Parse(item.Url)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+func TestItemFilter_Matches(t *testing.T) {
+	item := data.Item{Id: 1, Title: "Rewriting our Go services in Rust", Url: "https://blog.github.com/rewrite", Score: 120,
+		Descendants: 45, By: "Gopher", Type: "story", Time: int(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC).Unix())}
+	tests := []struct {
+		name   string
+		filter ItemFilter
+		want   bool
+	}{
+		{name: "Empty filter", filter: ItemFilter{}, want: true},
+		{name: "Keyword ignoring case", filter: ItemFilter{Keywords: []string{"rust"}}, want: true},
+		{name: "Any keyword", filter: ItemFilter{Keywords: []string{"zig", "go "}}, want: true},
+		{name: "Missing keyword", filter: ItemFilter{Keywords: []string{"zig"}}, want: false},
+		{name: "Minimum score", filter: ItemFilter{MinScore: 120}, want: true},
+		{name: "Score too low", filter: ItemFilter{MinScore: 121}, want: false},
+		{name: "Subdomain", filter: ItemFilter{Domains: []string{"github.com"}}, want: true},
+		{name: "Domain with www", filter: ItemFilter{Domains: []string{"www.blog.github.com"}}, want: true},
+		{name: "Domain suffix only", filter: ItemFilter{Domains: []string{"hub.com"}}, want: false},
+		{name: "Title pattern", filter: ItemFilter{Pattern: regexp.MustCompile(`(?i)^rewriting`)}, want: true},
+		{name: "Title pattern not matching", filter: ItemFilter{Pattern: regexp.MustCompile(`^Rust`)}, want: false},
+		{name: "Minimum comments", filter: ItemFilter{MinComments: 46}, want: false},
+		{name: "Excluded domain", filter: ItemFilter{ExcludedDomains: []string{"example.com", "github.com"}}, want: false},
+		{name: "Other excluded domain", filter: ItemFilter{ExcludedDomains: []string{"gitlab.com"}}, want: true},
+		{name: "Author ignoring case", filter: ItemFilter{Authors: []string{"pike", "gopher"}}, want: true},
+		{name: "Other author", filter: ItemFilter{Authors: []string{"pike"}}, want: false},
+		{name: "Type", filter: ItemFilter{Types: []string{"job"}}, want: false},
+		{name: "Within time window", filter: ItemFilter{Since: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}, want: true},
+		{name: "Before time window", filter: ItemFilter{Since: time.Date(2024, 6, 1, 12, 0, 1, 0, time.UTC)}, want: false},
+		{name: "Every criteria", filter: ItemFilter{Keywords: []string{"go"}, MinScore: 100, Domains: []string{"gitlab.com"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(item); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,15 @@
 package services
 
-import "github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+import (
+	"context"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
 
 //go:generate mockgen -source=itemRetriever.go -destination=mock/itemRetriever.go
 
+// Retriever fetches up to maxItems items from a source. Implementations must
+// abandon any outstanding work and return as soon as ctx is done.
 type Retriever interface {
-	GetItems(maxItems int) ([]data.Item, error)
+	GetItems(ctx context.Context, maxItems int) ([]data.Item, error)
 }
@@ -2,45 +2,101 @@ package services
 
 import (
 	"cmp"
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"slices"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type SourceConnectors struct {
 	SourceName string
 	Connector  Retriever
+	// Timeout bounds how long the source may take to answer. Zero means the
+	// source is only bounded by the caller's context.
+	Timeout time.Duration
+	// Weight is the share of the items of an aggregation given to the source,
+	// relative to the weights of the other sources. Zero counts as 1.
+	Weight float64
+	// Site identifies where the items of the source are listed, shared by
+	// the sources listing the same items such as the Hacker News lists.
+	// Sources without site are sites of their own.
+	Site string
 }
 
 type Aggregator struct {
 	Connectors []SourceConnectors
+	// PartialResults keeps the items of healthy sources when some others fail.
+	// The aggregation only fails when every source does.
+	PartialResults bool
+	// Ranker orders the aggregated items. The default ranking is used when nil.
+	Ranker Ranker
+	// Deduplicate merges the items of different sources linking the same
+	// story, recording every source of an item in its Sources.
+	Deduplicate bool
+	// Filter leaves out the items of every source not matching it before
+	// their ranking.
+	Filter ItemFilter
 }
 
+// filteredSourceItems is the number of items requested at least to every
+// source when filtering, so that filtered pages are still filled.
+const filteredSourceItems = 100
+
 type SourceFetchResult struct {
-	Items []data.Item
-	Error error
+	// index is the position of the source among the aggregator connectors,
+	// telling apart sources sharing a name.
+	index      int
+	SourceName string
+	Items      []data.Item
+	Error      error
+	Latency    time.Duration
+	// FetchedAt is when the items were fetched from the source, earlier than
+	// the aggregation for sources serving snapshots.
+	FetchedAt time.Time
+}
+
+func (agg *Aggregator) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	items, _, err := agg.GetItemsWithStatus(ctx, maxItems)
+	return items, err
 }
 
-func (agg *Aggregator) GetItems(maxItems int) ([]data.Item, error) {
+// GetItemsWithStatus returns the sorted items together with the outcome of
+// every source fetch, in the same order as the aggregator connectors.
+func (agg *Aggregator) GetItemsWithStatus(ctx context.Context, maxItems int) ([]data.Item, []data.SourceStatus, error) {
 	connectorsNames := make([]string, len(agg.Connectors))
 	for i, cnn := range agg.Connectors {
 		connectorsNames[i] = cnn.SourceName
 	}
-	log.Printf("Fetching results from sources: %s", strings.Join(connectorsNames, ","))
-	itemsPerSource := maxItems / len(agg.Connectors)
-	aggregatedItems := make([]data.Item, 0)
+	slog.InfoContext(ctx, "Fetching results from sources", "sources", connectorsNames, "max_items", maxItems)
+	ctx, span := tracer.Start(ctx, "Aggregator.GetItems", trace.WithAttributes(attribute.StringSlice("scraper.sources", connectorsNames), attribute.Int("scraper.max_items", maxItems)))
+	defer span.End()
+	itemsPerSource := agg.itemsPerSource(maxItems)
+	// Sources still running when we give up are cancelled on return.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	var wg sync.WaitGroup
-	channel := make(chan SourceFetchResult)
-	for _, sourceConnector := range agg.Connectors {
-		connector := sourceConnector.Connector
+	channel := make(chan SourceFetchResult, len(agg.Connectors))
+	for index, sourceConnector := range agg.Connectors {
 		wg.Add(1)
 		go func() {
-			items, err := connector.GetItems(itemsPerSource)
-			channel <- SourceFetchResult{Items: items, Error: err}
-			wg.Done()
+			defer wg.Done()
+			ctx, span := tracer.Start(ctx, "Aggregator.fetchSource", trace.WithAttributes(sourceAttribute(sourceConnector.SourceName)))
+			start := time.Now()
+			fetched := itemsPerSource[index]
+			if !agg.Filter.IsEmpty() {
+				fetched = max(fetched, filteredSourceItems)
+			}
+			items, fetchedAt, err := fetchSource(ctx, sourceConnector, fetched)
+			endSpan(span, err)
+			channel <- SourceFetchResult{index: index, SourceName: sourceConnector.SourceName, Items: items, Error: err, Latency: time.Since(start), FetchedAt: fetchedAt}
 		}()
 	}
 
@@ -49,39 +105,127 @@ func (agg *Aggregator) GetItems(maxItems int) ([]data.Item, error) {
 		close(channel)
 	}()
 
+	sourcesStatus := make([]data.SourceStatus, len(agg.Connectors))
+	sourcesItems := make([][]data.Item, len(agg.Connectors))
+	errs := make([]error, 0)
 	for fetchResponse := range channel {
 		items := fetchResponse.Items
 		err := fetchResponse.Error
+		sourcesStatus[fetchResponse.index] = sourceStatus(fetchResponse)
 		if err != nil {
-			return nil, err
+			if !agg.PartialResults {
+				return nil, nil, err
+			}
+			slog.WarnContext(ctx, "Discarding results from source", "source", fetchResponse.SourceName, "error", err)
+			errs = append(errs, err)
+			continue
 		}
-		if len(items) >= itemsPerSource {
-			aggregatedItems = append(aggregatedItems, items[:itemsPerSource]...)
-		} else {
-			aggregatedItems = append(aggregatedItems, items...)
+		if !agg.Filter.IsEmpty() {
+			items = agg.Filter.Apply(items)
 		}
+		sourcesItems[fetchResponse.index] = items[:min(len(items), itemsPerSource[fetchResponse.index])]
+	}
+
+	if len(errs) == len(agg.Connectors) {
+		err := errors.Join(errs...)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, sourcesStatus, err
 	}
-	aggregatedItems = sortByTitleType(aggregatedItems)
-	return aggregatedItems, nil
-}
 
-func sortByTitleType(items []data.Item) []data.Item {
-	longTitleItems := make([]data.Item, 0)
-	shortTitleItems := make([]data.Item, 0)
-	for _, item := range items {
-		if len(item.Title) < 5 {
-			shortTitleItems = append(shortTitleItems, item)
-		} else {
-			longTitleItems = append(longTitleItems, item)
+	start := time.Now()
+	defer func() { aggregatorMergeDuration.Observe(time.Since(start).Seconds()) }()
+	// Items are merged in connectors order, so duplicates keep the data of
+	// the first source listing them.
+	aggregatedItems := make([]data.Item, 0)
+	sites := make([]string, 0)
+	for i, cnn := range agg.Connectors {
+		for _, item := range sourcesItems[i] {
+			if agg.Deduplicate {
+				item.Sources = []data.ItemSource{{Source: cnn.SourceName, Id: item.Id, Url: item.Url, Score: item.Score, Comments: item.Descendants}}
+			}
+			aggregatedItems = append(aggregatedItems, item)
+			sites = append(sites, cmp.Or(cnn.Site, cnn.SourceName))
 		}
 	}
+	if agg.Deduplicate {
+		aggregatedItems = deduplicate(aggregatedItems, sites)
+	}
+	ranker := agg.Ranker
+	if ranker == nil {
+		ranker = Rankers[DefaultRanking]
+	}
+	aggregatedItems = ranker.Rank(aggregatedItems)
+	span.SetAttributes(attribute.Int("scraper.items", len(aggregatedItems)))
+	return aggregatedItems, sourcesStatus, nil
+}
 
-	slices.SortFunc(longTitleItems, func(a, b data.Item) int {
-		return cmp.Compare(b.Descendants, a.Descendants)
-	})
+// itemsPerSource splits maxItems between the sources by their weight, in
+// connectors order. The items left by rounding the shares down go to the
+// heaviest sources, so that the shares add up to maxItems.
+func (agg *Aggregator) itemsPerSource(maxItems int) []int {
+	totalWeight := 0.0
+	for _, cnn := range agg.Connectors {
+		totalWeight += cnn.weight()
+	}
+	itemsPerSource := make([]int, len(agg.Connectors))
+	remainder := maxItems
+	for i, cnn := range agg.Connectors {
+		itemsPerSource[i] = int(float64(maxItems) * cnn.weight() / totalWeight)
+		remainder -= itemsPerSource[i]
+	}
+	heaviest := make([]int, len(agg.Connectors))
+	for i := range heaviest {
+		heaviest[i] = i
+	}
+	slices.SortStableFunc(heaviest, func(a, b int) int { return cmp.Compare(agg.Connectors[b].weight(), agg.Connectors[a].weight()) })
+	for i := 0; remainder > 0 && len(heaviest) > 0; i, remainder = i+1, remainder-1 {
+		itemsPerSource[heaviest[i%len(heaviest)]]++
+	}
+	return itemsPerSource
+}
+
+func (source SourceConnectors) weight() float64 {
+	if source.Weight == 0 {
+		return 1
+	}
+	return source.Weight
+}
 
-	slices.SortFunc(shortTitleItems, func(a, b data.Item) int {
-		return cmp.Compare(b.Score, a.Score)
-	})
-	return append(longTitleItems, shortTitleItems...)
+func sourceStatus(result SourceFetchResult) data.SourceStatus {
+	status := data.SourceStatus{Source: result.SourceName, State: data.SourceOk, LatencyMs: result.Latency.Milliseconds()}
+	if result.Error != nil {
+		switch {
+		case errors.Is(result.Error, ErrCircuitOpen):
+			status.State = data.SourceSkipped
+		case errors.Is(result.Error, context.DeadlineExceeded):
+			status.State = data.SourceTimedOut
+		default:
+			status.State = data.SourceFailed
+		}
+		status.Error = result.Error.Error()
+	} else {
+		status.FetchedAt = &result.FetchedAt
+	}
+	return status
+}
+
+func fetchSource(ctx context.Context, source SourceConnectors, maxItems int) ([]data.Item, time.Time, error) {
+	if source.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, source.Timeout)
+		defer cancel()
+	}
+	var items []data.Item
+	var fetchedAt time.Time
+	var err error
+	if snapshots, ok := source.Connector.(SnapshotRetriever); ok {
+		items, fetchedAt, err = snapshots.GetSnapshot(ctx, maxItems)
+	} else {
+		items, err = source.Connector.GetItems(ctx, maxItems)
+		fetchedAt = time.Now()
+	}
+	if err != nil {
+		return nil, fetchedAt, fmt.Errorf("%s: %w", source.SourceName, err)
+	}
+	return items, fetchedAt, nil
 }
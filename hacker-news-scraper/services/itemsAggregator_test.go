@@ -1,28 +1,31 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
 	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
 	"github.com/golang/mock/gomock"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestAggregator_GetItems(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockItemsApiResponse := `[{"by":"testApi1","descendants":12,"id":40540952,"kids":[],"score":746,"time":1717194188,"title":"Test1 item: more that 5","type":"story","url":"https://test/test1"},{"by":"testApi2","descendants":25,"id":40541559,"kids":[],"score":250,"time":1717194188,"title":"1234","type":"story","url":"https://test/test2"},{"by":"testApi3","descendants":55,"id":40540954,"kids":[],"score":111,"time":1717194188,"title":"Test3 item: more that 5","type":"story","url":"https://test/test"}]`
+	mockItemsApiResponse := `[{"by":"testApi1","descendants":12,"id":40540952,"kids":[],"score":746,"time":1717194188,"title":"Test1 item: more than 5 words","type":"story","url":"https://test/test1"},{"by":"testApi2","descendants":25,"id":40541559,"kids":[],"score":250,"time":1717194188,"title":"1234","type":"story","url":"https://test/test2"},{"by":"testApi3","descendants":55,"id":40540954,"kids":[],"score":111,"time":1717194188,"title":"Test3 item: more than 5 words","type":"story","url":"https://test/test"}]`
 	mockApiFetcher, itemsApiRetriever := createFetcherMock(mockItemsApiResponse, t, ctrl)
 
 	mockItemsWebResponse := `[{"by":"testWeb1","descendants":99,"id":40540952,"kids":[],"score":746,"time":1717194188,"title":"123","type":"story","url":"https://testweb/test1"},{"by":"testWeb2","descendants":1,"id":40541559,"kids":[],"score":250,"time":1717194188,"title":"Test web 2 item: more that 5","type":"story","url":"https://testweb/test2"},{"by":"testWeb3","descendants":80,"id":40540954,"kids":[],"score":1,"time":1717194188,"title":"Test web 3 item: more that 5","type":"story","url":"https://testweb/test3"}]`
 	mockWebFetcher, itemsWebRetriever := createFetcherMock(mockItemsWebResponse, t, ctrl)
 
 	// Set up expectations
-	mockApiFetcher.EXPECT().GetItems(gomock.Any()).Return(itemsApiRetriever, nil).AnyTimes()
+	mockApiFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(itemsApiRetriever, nil).AnyTimes()
 	apiRetriever := SourceConnectors{SourceName: "testApi", Connector: mockApiFetcher}
-	mockWebFetcher.EXPECT().GetItems(gomock.Any()).Return(itemsWebRetriever, nil).AnyTimes()
+	mockWebFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(itemsWebRetriever, nil).AnyTimes()
 	webRetriever := SourceConnectors{SourceName: "testWeb", Connector: mockWebFetcher}
 	wantApiFetchResult := []data.Item{itemsApiRetriever[2], itemsApiRetriever[0], itemsApiRetriever[1]}
 	wantWebFetchResult := []data.Item{itemsWebRetriever[2], itemsWebRetriever[1], itemsWebRetriever[0]}
@@ -50,7 +53,7 @@ func TestAggregator_GetItems(t *testing.T) {
 			agg := &Aggregator{
 				Connectors: tt.fields.Connectors,
 			}
-			got, err := agg.GetItems(tt.args.maxItems)
+			got, err := agg.GetItems(context.Background(), tt.args.maxItems)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetItems() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -71,3 +74,133 @@ func createFetcherMock(mockItemResponse string, t *testing.T, ctrl *gomock.Contr
 	}
 	return mockApiFetcher, items
 }
+
+func TestAggregator_GetItemsSourceTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	slowFetcher := mock_services.NewMockRetriever(ctrl)
+	slowFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ int) ([]data.Item, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	agg := &Aggregator{
+		Connectors: []SourceConnectors{{SourceName: "slow", Connector: slowFetcher, Timeout: 10 * time.Millisecond}},
+	}
+
+	got, err := agg.GetItems(context.Background(), 10)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetItems() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got != nil {
+		t.Errorf("GetItems() got = %v, want nil", got)
+	}
+}
+
+func TestAggregator_GetItemsWithStatusPartialResults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockItemsApiResponse := `[{"by":"testApi1","descendants":12,"id":40540952,"kids":[],"score":746,"time":1717194188,"title":"Test1 item: more that 5","type":"story","url":"https://test/test1"}]`
+	mockApiFetcher, itemsApiRetriever := createFetcherMock(mockItemsApiResponse, t, ctrl)
+	mockApiFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(itemsApiRetriever, nil).AnyTimes()
+	mockWebFetcher := mock_services.NewMockRetriever(ctrl)
+	mockWebFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(nil, errors.New("web down")).AnyTimes()
+	apiRetriever := SourceConnectors{SourceName: "testApi", Connector: mockApiFetcher}
+	webRetriever := SourceConnectors{SourceName: "testWeb", Connector: mockWebFetcher}
+
+	tests := []struct {
+		name       string
+		connectors []SourceConnectors
+		partial    bool
+		want       []data.Item
+		wantStates []data.SourceState
+		wantErr    bool
+	}{
+		{name: "Strict mode fails", connectors: []SourceConnectors{apiRetriever, webRetriever}, partial: false, want: nil, wantErr: true},
+		{name: "Partial mode keeps healthy sources", connectors: []SourceConnectors{apiRetriever, webRetriever}, partial: true, want: itemsApiRetriever, wantStates: []data.SourceState{data.SourceOk, data.SourceFailed}},
+		{name: "Partial mode fails when every source fails", connectors: []SourceConnectors{webRetriever}, partial: true, want: nil, wantStates: []data.SourceState{data.SourceFailed}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := &Aggregator{Connectors: tt.connectors, PartialResults: tt.partial}
+			got, statuses, err := agg.GetItemsWithStatus(context.Background(), 10)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetItemsWithStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetItemsWithStatus() got = %v, want %v", got, tt.want)
+			}
+			for i, wantState := range tt.wantStates {
+				if statuses[i].State != wantState {
+					t.Errorf("GetItemsWithStatus() source %s state = %v, want %v", statuses[i].Source, statuses[i].State, wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestAggregator_GetItemsSharedNames(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	mockFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return([]data.Item{{Id: 1, Title: "Listed"}}, nil)
+	failingFetcher := mock_services.NewMockRetriever(ctrl)
+	failingFetcher.EXPECT().GetItems(gomock.Any(), gomock.Any()).Return(nil, errors.New("source down"))
+	agg := &Aggregator{Connectors: []SourceConnectors{{SourceName: "test", Connector: mockFetcher}, {SourceName: "test", Connector: failingFetcher}}, PartialResults: true}
+
+	items, statuses, err := agg.GetItemsWithStatus(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("GetItemsWithStatus() error = %v", err)
+	}
+	if len(items) != 1 || items[0].Id != 1 {
+		t.Errorf("GetItemsWithStatus() items = %+v, want the items of the first source once", items)
+	}
+	if len(statuses) != 2 || statuses[0].State != data.SourceOk || statuses[1].State != data.SourceFailed {
+		t.Errorf("GetItemsWithStatus() statuses = %+v, want every source its own status", statuses)
+	}
+}
+
+func TestAggregator_GetItemsWeights(t *testing.T) {
+	tests := []struct {
+		name      string
+		weights   []float64
+		wantItems []int
+	}{
+		{name: "Same weight", weights: []float64{0, 0}, wantItems: []int{10, 10}},
+		{name: "Heavier source", weights: []float64{3, 1}, wantItems: []int{15, 5}},
+		{name: "Default weight", weights: []float64{0, 0.25}, wantItems: []int{16, 4}},
+		{name: "Rounding remainder", weights: []float64{1, 1, 1}, wantItems: []int{7, 7, 6}},
+		{name: "Remainder to heaviest sources", weights: []float64{1, 1, 1, 4}, wantItems: []int{3, 3, 2, 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			connectors := make([]SourceConnectors, len(tt.weights))
+			for i, weight := range tt.weights {
+				fetcher := mock_services.NewMockRetriever(ctrl)
+				fetcher.EXPECT().GetItems(gomock.Any(), tt.wantItems[i]).DoAndReturn(func(_ context.Context, maxItems int) ([]data.Item, error) {
+					items := make([]data.Item, maxItems)
+					for j := range items {
+						items[j] = data.Item{Id: data.ItemId(i*100 + j), Title: "Item"}
+					}
+					return items, nil
+				})
+				// Sources sharing a name still get their own share.
+				connectors[i] = SourceConnectors{SourceName: "test", Connector: fetcher, Weight: weight}
+			}
+			agg := &Aggregator{Connectors: connectors}
+			got, err := agg.GetItems(context.Background(), 20)
+			if err != nil {
+				t.Fatalf("GetItems() error = %v", err)
+			}
+			if len(got) != 20 {
+				t.Errorf("GetItems() got %d items, want 20", len(got))
+			}
+		})
+	}
+}
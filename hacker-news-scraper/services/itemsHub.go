@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+// seenRetention is how long an item is remembered after it was last listed
+// by its source, so that items coming back are not published again.
+const seenRetention = 24 * time.Hour
+
+// DiscoveredItem is an item newly listed by the source keyed Source.
+type DiscoveredItem struct {
+	Source string
+	Item   data.Item
+}
+
+// ItemsHub fetches every interval the sources of a registry followed by its
+// subscribers, publishing to them the items the sources did not list before.
+// The first fetch of a source only records its items, and sources no longer
+// followed are forgotten. Fetching polled or cached sources reads their
+// snapshots, so items are published as the poller or the cache discovers
+// them.
+type ItemsHub struct {
+	Registry *SourceRegistry
+	Interval time.Duration
+	MaxItems int
+
+	now         func() time.Time
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	// seen holds, per source key, when every item was last listed.
+	seen map[string]map[string]time.Time
+}
+
+func NewItemsHub(registry *SourceRegistry, interval time.Duration, maxItems int) *ItemsHub {
+	return &ItemsHub{Registry: registry, Interval: interval, MaxItems: maxItems, now: time.Now,
+		subscribers: make(map[*Subscription]struct{}), seen: make(map[string]map[string]time.Time)}
+}
+
+// Subscription receives on Items the items discovered in the sources it
+// follows, none until Follow is called.
+type Subscription struct {
+	Items <-chan []DiscoveredItem
+
+	hub     *ItemsHub
+	channel chan []DiscoveredItem
+	// following is set by Follow, sources being every source when empty.
+	following bool
+	sources   []string
+	once      sync.Once
+}
+
+// Subscribe returns a subscription to the items discovered from now on.
+// Discoveries are dropped for subscribers not keeping up with buffer pending
+// ones.
+func (h *ItemsHub) Subscribe(buffer int) *Subscription {
+	channel := make(chan []DiscoveredItem, buffer)
+	subscription := &Subscription{Items: channel, hub: h, channel: channel}
+	h.mu.Lock()
+	h.subscribers[subscription] = struct{}{}
+	h.mu.Unlock()
+	return subscription
+}
+
+// Follow replaces the sources followed by s, every source when empty.
+func (s *Subscription) Follow(sources []string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.following, s.sources = true, slices.Clone(sources)
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subscribers, s)
+		s.hub.mu.Unlock()
+	})
+}
+
+func (s *Subscription) follows(key string) bool {
+	return s.following && (len(s.sources) == 0 || slices.Contains(s.sources, key))
+}
+
+// Run fetches the sources every interval until ctx is done.
+func (h *ItemsHub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+	for {
+		h.fetch(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// followed returns the keys of the sources followed by some subscriber,
+// forgetting the items of the others.
+func (h *ItemsHub) followed() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]string, 0)
+	for _, key := range h.Registry.Keys() {
+		followed := false
+		for subscriber := range h.subscribers {
+			followed = followed || subscriber.follows(key)
+		}
+		if followed {
+			keys = append(keys, key)
+		} else {
+			delete(h.seen, key)
+		}
+	}
+	return keys
+}
+
+func (h *ItemsHub) fetch(ctx context.Context) {
+	keys := h.followed()
+	if len(keys) == 0 {
+		return
+	}
+	results := make([][]data.Item, len(keys))
+	var waitGroup sync.WaitGroup
+	for i, key := range keys {
+		source, _ := h.Registry.Get(key)
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			items, _, err := fetchSource(ctx, source, h.MaxItems)
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to look for new items", "error", err)
+				return
+			}
+			results[i] = items
+		}()
+	}
+	waitGroup.Wait()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	discovered := make([]DiscoveredItem, 0)
+	for i, key := range keys {
+		if results[i] == nil {
+			continue
+		}
+		seen, known := h.seen[key]
+		if !known {
+			seen = make(map[string]time.Time)
+			h.seen[key] = seen
+		}
+		for _, item := range results[i] {
+			id := ItemId(item)
+			if _, ok := seen[id]; !ok && known {
+				discovered = append(discovered, DiscoveredItem{Source: key, Item: item})
+			}
+			seen[id] = h.now()
+		}
+		for id, lastSeen := range seen {
+			if h.now().Sub(lastSeen) > seenRetention {
+				delete(seen, id)
+			}
+		}
+	}
+	if len(discovered) == 0 {
+		return
+	}
+	for subscriber := range h.subscribers {
+		followed := slices.DeleteFunc(slices.Clone(discovered), func(discovery DiscoveredItem) bool { return !subscriber.follows(discovery.Source) })
+		if len(followed) == 0 {
+			continue
+		}
+		select {
+		case subscriber.channel <- followed:
+		default:
+			slog.WarnContext(ctx, "Dropping new items for a slow subscriber", "items", len(followed))
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestItemsHub_Discoveries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	first := data.Item{Id: 1, Title: "First"}
+	second := data.Item{Id: 2, Title: "Second"}
+	third := data.Item{Id: 3, Title: "Third"}
+	hnFetcher := mock_services.NewMockRetriever(ctrl)
+	lobstersFetcher := mock_services.NewMockRetriever(ctrl)
+	registry := NewSourceRegistry()
+	registry.Register("hn", SourceConnectors{SourceName: "Hacker News", Connector: hnFetcher})
+	registry.Register("lobsters", SourceConnectors{SourceName: "Lobsters", Connector: lobstersFetcher})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	hub := NewItemsHub(registry, time.Minute, 10)
+	hub.now = func() time.Time { return now }
+	// Sources are not fetched without subscribers following them.
+	hub.fetch(context.Background())
+	subscription := hub.Subscribe(4)
+	hub.fetch(context.Background())
+	subscription.Follow(nil)
+	discoveries := subscription.Items
+
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		hnItems       []data.Item
+		lobstersItems []data.Item
+		lobstersErr   error
+		want          []DiscoveredItem
+	}{
+		{name: "First fetch records items", hnItems: []data.Item{first, second}, lobstersErr: context.DeadlineExceeded},
+		{name: "New items are published", hnItems: []data.Item{third, first}, lobstersItems: []data.Item{first},
+			want: []DiscoveredItem{{Source: "hn", Item: third}}},
+		{name: "Items coming back are not published", hnItems: []data.Item{second, third}, lobstersItems: []data.Item{first, second},
+			want: []DiscoveredItem{{Source: "lobsters", Item: second}}},
+		{name: "Forgotten items are published again", advance: 25 * time.Hour, hnItems: []data.Item{third}, lobstersItems: []data.Item{first, second}},
+		{name: "Items gone for long are new", hnItems: []data.Item{first, third}, lobstersItems: []data.Item{first, second},
+			want: []DiscoveredItem{{Source: "hn", Item: first}}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now = now.Add(step.advance)
+			hnFetcher.EXPECT().GetItems(gomock.Any(), 10).Return(step.hnItems, nil)
+			lobstersFetcher.EXPECT().GetItems(gomock.Any(), 10).Return(step.lobstersItems, step.lobstersErr)
+			hub.fetch(context.Background())
+			var got []DiscoveredItem
+			select {
+			case got = <-discoveries:
+			default:
+			}
+			if !reflect.DeepEqual(got, step.want) {
+				t.Errorf("published %+v, want %+v", got, step.want)
+			}
+		})
+	}
+
+	// Only the followed sources are fetched, the others being forgotten.
+	subscription.Follow([]string{"hn"})
+	hnFetcher.EXPECT().GetItems(gomock.Any(), 10).Return([]data.Item{first, third}, nil)
+	hub.fetch(context.Background())
+	subscription.Follow([]string{"lobsters"})
+	lobstersFetcher.EXPECT().GetItems(gomock.Any(), 10).Return([]data.Item{{Id: 4, Title: "Fourth"}}, nil)
+	hub.fetch(context.Background())
+	if len(discoveries) != 0 {
+		t.Errorf("published the first items of a newly followed source")
+	}
+
+	subscription.Close()
+	hub.fetch(context.Background())
+	if len(discoveries) != 0 {
+		t.Errorf("published items after unsubscribing")
+	}
+}
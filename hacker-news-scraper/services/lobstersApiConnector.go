@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+const LobstersHottest = "hottest"
+const LobstersNewest = "newest"
+
+// maxLobstersPages bounds the pages requested to fill maxItems, Lobsters
+// serving 25 stories per page.
+const maxLobstersPages = 10
+
+// LobstersAPIConnector retrieves stories from the Lobsters JSON API.
+type LobstersAPIConnector struct {
+	Url string
+	// EndPoint is the list to retrieve: "hottest", "newest" or "t/<tag>".
+	EndPoint string
+	// Client is used for every request. http.DefaultClient is used when nil.
+	Client *http.Client
+}
+
+type lobstersStory struct {
+	ShortId      string       `json:"short_id"`
+	ShortIdUrl   string       `json:"short_id_url"`
+	CreatedAt    time.Time    `json:"created_at"`
+	Title        string       `json:"title"`
+	Url          string       `json:"url"`
+	Score        int          `json:"score"`
+	CommentCount int          `json:"comment_count"`
+	CommentsUrl  string       `json:"comments_url"`
+	Submitter    lobstersUser `json:"submitter_user"`
+	Tags         []string     `json:"tags"`
+}
+
+// lobstersUser accepts both the username string served by the current API
+// and the user object served by older versions.
+type lobstersUser string
+
+func (u *lobstersUser) UnmarshalJSON(raw []byte) error {
+	var username string
+	if err := json.Unmarshal(raw, &username); err == nil {
+		*u = lobstersUser(username)
+		return nil
+	}
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(raw, &user); err != nil {
+		return err
+	}
+	*u = lobstersUser(user.Username)
+	return nil
+}
+
+func (c *LobstersAPIConnector) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	items := make([]data.Item, 0, maxItems)
+	for page := 1; len(items) < maxItems && page <= maxLobstersPages; page++ {
+		var stories []lobstersStory
+		if err := fetchJSON(ctx, c.Client, c.pageUrl(page), &stories); err != nil {
+			slog.WarnContext(ctx, "Failed to get Lobsters page", "endpoint", c.EndPoint, "page", page, "error", err)
+			if page == 1 || ctx.Err() != nil {
+				return nil, err
+			}
+			// Keep the stories of the previous pages.
+			break
+		}
+		if len(stories) == 0 {
+			break
+		}
+		for _, story := range stories[:min(len(stories), maxItems-len(items))] {
+			items = append(items, story.toItem())
+		}
+	}
+	if len(items) == 0 && maxItems > 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoItems, c.EndPoint)
+	}
+	return items, nil
+}
+
+func (c *LobstersAPIConnector) pageUrl(page int) string {
+	baseUrl := strings.TrimRight(c.Url, "/")
+	endPoint := c.EndPoint
+	if endPoint == "" {
+		endPoint = LobstersHottest
+	}
+	switch {
+	case page == 1:
+		return fmt.Sprintf("%s/%s.json", baseUrl, endPoint)
+	case endPoint == LobstersHottest:
+		return fmt.Sprintf("%s/page/%d.json", baseUrl, page)
+	default:
+		return fmt.Sprintf("%s/%s/page/%d.json", baseUrl, endPoint, page)
+	}
+}
+
+func (s lobstersStory) toItem() data.Item {
+	id, _ := strconv.ParseInt(s.ShortId, 36, 64)
+	storyUrl := s.Url
+	if storyUrl == "" {
+		storyUrl = s.ShortIdUrl
+	}
+	return data.Item{
+		By:          string(s.Submitter),
+		Descendants: s.CommentCount,
+		Id:          data.ItemId(id),
+		Score:       s.Score,
+		Time:        int(s.CreatedAt.Unix()),
+		Title:       s.Title,
+		Type:        "story",
+		Url:         storyUrl,
+		ShortId:     s.ShortId,
+		Tags:        s.Tags,
+	}
+}
+
+type lobstersStoryComments struct {
+	Comments []lobstersComment `json:"comments"`
+}
+
+type lobstersComment struct {
+	ShortId       string       `json:"short_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+	IsDeleted     bool         `json:"is_deleted"`
+	IsModerated   bool         `json:"is_moderated"`
+	ParentComment string       `json:"parent_comment"`
+	Comment       string       `json:"comment"`
+	CommentingBy  lobstersUser `json:"commenting_user"`
+}
+
+// GetComments builds the comment thread of a story from its JSON page, which
+// lists every comment in thread order along with its parent.
+func (c *LobstersAPIConnector) GetComments(ctx context.Context, storyId string, options CommentOptions) (data.CommentThread, error) {
+	thread := data.CommentThread{StoryId: storyId, Comments: make([]data.Comment, 0)}
+	if storyId == "" || strings.ContainsAny(storyId, "/?#.") {
+		return thread, ErrStoryNotFound
+	}
+	var story lobstersStoryComments
+	reqUrl := fmt.Sprintf("%s/s/%s.json", strings.TrimRight(c.Url, "/"), storyId)
+	if err := fetchJSON(ctx, c.Client, reqUrl, &story); err != nil {
+		var statusErr StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return thread, ErrStoryNotFound
+		}
+		slog.WarnContext(ctx, "Failed to get Lobsters story comments", "story_id", storyId, "error", err)
+		return thread, err
+	}
+
+	budget := newCommentBudget(options.MaxComments)
+	replies := make(map[string][]data.Comment)
+	depths := make(map[string]int, len(story.Comments))
+	for _, comment := range story.Comments {
+		depth := depths[comment.ParentComment] + 1
+		if depth > options.MaxDepth {
+			thread.Truncated = true
+			continue
+		}
+		if _, ok := depths[comment.ParentComment]; comment.ParentComment != "" && !ok {
+			// The parent was left out of the thread, so is its reply.
+			continue
+		}
+		if !budget.take() {
+			break
+		}
+		depths[comment.ShortId] = depth
+		replies[comment.ParentComment] = append(replies[comment.ParentComment], data.Comment{
+			Id:      comment.ShortId,
+			By:      string(comment.CommentingBy),
+			Time:    int(comment.CreatedAt.Unix()),
+			Text:    comment.Comment,
+			Deleted: comment.IsDeleted,
+			Dead:    comment.IsModerated,
+		})
+	}
+	if topLevel := buildCommentTree(replies, ""); topLevel != nil {
+		thread.Comments = topLevel
+	}
+	thread.Truncated = thread.Truncated || budget.truncated.Load()
+	return thread, nil
+}
+
+func buildCommentTree(replies map[string][]data.Comment, parent string) []data.Comment {
+	comments := replies[parent]
+	for i := range comments {
+		comments[i].Replies = buildCommentTree(replies, comments[i].Id)
+	}
+	return comments
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+const mockLobstersStory1 = `{"short_id":"one3oq","short_id_url":"https://lobste.rs/s/one3oq","created_at":"2024-06-08T09:04:23.000-05:00","title":"Stupid Slow: The Perceived Speed of Computers","url":"https://www.datagubbe.se/stupidslow/","score":21,"flags":0,"comment_count":4,"description":"","description_plain":"","comments_url":"https://lobste.rs/s/one3oq/stupid_slow_perceived_speed_computers","submitter_user":"mwcampbell","user_is_author":false,"tags":["performance"]}`
+const mockLobstersStory2 = `{"short_id":"hilmze","short_id_url":"https://lobste.rs/s/hilmze","created_at":"2024-06-09T01:22:13.000-05:00","title":"Ask: what are you working on?","url":"","score":104,"flags":0,"comment_count":10,"comments_url":"https://lobste.rs/s/hilmze/ask_what_are_you_working_on","submitter_user":{"username":"jwz"},"tags":["ask","programming"]}`
+
+func TestLobstersAPIConnector_GetItems(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hottest.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf("[%s,%s]", mockLobstersStory1, mockLobstersStory2)))
+	})
+	mux.HandleFunc("/newest.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf("[%s]", mockLobstersStory1)))
+	})
+	mux.HandleFunc("/newest/page/2.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf("[%s]", mockLobstersStory2)))
+	})
+	mux.HandleFunc("/newest/page/3.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	mux.HandleFunc("/t/performance.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf("[%s]", mockLobstersStory1)))
+	})
+	mux.HandleFunc("/t/empty.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	mux.HandleFunc("/t/invalid.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	item1 := data.Item{By: "mwcampbell", Descendants: 4, Id: 1490477354, Score: 21, Time: 1717855463, Title: "Stupid Slow: The Perceived Speed of Computers", Type: "story", Url: "https://www.datagubbe.se/stupidslow/", ShortId: "one3oq", Tags: []string{"performance"}}
+	item2 := data.Item{By: "jwz", Descendants: 10, Id: 1059167642, Score: 104, Time: 1717914133, Title: "Ask: what are you working on?", Type: "story", Url: "https://lobste.rs/s/hilmze", ShortId: "hilmze", Tags: []string{"ask", "programming"}}
+	tests := []struct {
+		name     string
+		endPoint string
+		maxItems int
+		want     []data.Item
+		wantErr  bool
+	}{
+		{name: "Hottest stories", endPoint: LobstersHottest, maxItems: 10, want: []data.Item{item1, item2}},
+		{name: "Hottest stories limited", endPoint: LobstersHottest, maxItems: 1, want: []data.Item{item1}},
+		{name: "Newest stories across pages", endPoint: LobstersNewest, maxItems: 10, want: []data.Item{item1, item2}},
+		{name: "Tag stories", endPoint: "t/performance", maxItems: 10, want: []data.Item{item1}},
+		{name: "No stories", endPoint: "t/empty", maxItems: 10, wantErr: true},
+		{name: "Invalid response", endPoint: "t/invalid", maxItems: 10, wantErr: true},
+		{name: "Error in remote server", endPoint: "t/unknown", maxItems: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &LobstersAPIConnector{Url: ts.URL + "/", EndPoint: tt.endPoint, Client: ts.Client()}
+			got, err := c.GetItems(context.Background(), tt.maxItems)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetItems() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetItems() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLobstersAPIConnector_PageUrl(t *testing.T) {
+	tests := []struct {
+		endPoint string
+		page     int
+		want     string
+	}{
+		{endPoint: LobstersHottest, page: 1, want: "https://lobste.rs/hottest.json"},
+		{endPoint: LobstersHottest, page: 2, want: "https://lobste.rs/page/2.json"},
+		{endPoint: LobstersNewest, page: 3, want: "https://lobste.rs/newest/page/3.json"},
+		{endPoint: "t/go", page: 2, want: "https://lobste.rs/t/go/page/2.json"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join([]string{tt.endPoint, fmt.Sprint(tt.page)}, "/"), func(t *testing.T) {
+			c := &LobstersAPIConnector{Url: "https://lobste.rs/", EndPoint: tt.endPoint}
+			if got := c.pageUrl(tt.page); got != tt.want {
+				t.Errorf("pageUrl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const mockLobstersComments = `{"short_id":"one3oq","title":"Stupid Slow: The Perceived Speed of Computers","comments":[
+{"short_id":"c1","created_at":"2024-06-08T10:00:00.000-05:00","is_deleted":false,"is_moderated":false,"comment":"<p>First</p>","depth":0,"commenting_user":"alice"},
+{"short_id":"c2","created_at":"2024-06-08T10:05:00.000-05:00","is_deleted":false,"is_moderated":false,"comment":"<p>Reply</p>","depth":1,"parent_comment":"c1","commenting_user":{"username":"bob"}},
+{"short_id":"c3","created_at":"2024-06-08T10:10:00.000-05:00","is_deleted":true,"is_moderated":false,"comment":"","depth":2,"parent_comment":"c2","commenting_user":"carol"},
+{"short_id":"c4","created_at":"2024-06-08T10:15:00.000-05:00","is_deleted":false,"is_moderated":true,"comment":"<p>Moderated</p>","depth":0,"commenting_user":"dave"}]}`
+
+func TestLobstersAPIConnector_GetComments(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/s/one3oq.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mockLobstersComments))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	deleted := data.Comment{Id: "c3", By: "carol", Time: 1717859400, Deleted: true}
+	reply := data.Comment{Id: "c2", By: "bob", Time: 1717859100, Text: "<p>Reply</p>", Replies: []data.Comment{deleted}}
+	first := data.Comment{Id: "c1", By: "alice", Time: 1717858800, Text: "<p>First</p>", Replies: []data.Comment{reply}}
+	moderated := data.Comment{Id: "c4", By: "dave", Time: 1717859700, Text: "<p>Moderated</p>", Dead: true}
+	shallowFirst := first
+	shallowFirst.Replies = nil
+	tests := []struct {
+		name    string
+		storyId string
+		options CommentOptions
+		want    data.CommentThread
+		wantErr error
+	}{
+		{name: "Whole thread", storyId: "one3oq", options: CommentOptions{MaxDepth: 5, MaxComments: 10},
+			want: data.CommentThread{StoryId: "one3oq", Comments: []data.Comment{first, moderated}}},
+		{name: "Depth limit", storyId: "one3oq", options: CommentOptions{MaxDepth: 1, MaxComments: 10},
+			want: data.CommentThread{StoryId: "one3oq", Comments: []data.Comment{shallowFirst, moderated}, Truncated: true}},
+		{name: "Size limit", storyId: "one3oq", options: CommentOptions{MaxDepth: 5, MaxComments: 2},
+			want: data.CommentThread{StoryId: "one3oq", Comments: []data.Comment{{Id: "c1", By: "alice", Time: 1717858800, Text: "<p>First</p>",
+				Replies: []data.Comment{{Id: "c2", By: "bob", Time: 1717859100, Text: "<p>Reply</p>"}}}}, Truncated: true}},
+		{name: "Unknown story", storyId: "zzzzzz", options: CommentOptions{MaxDepth: 5, MaxComments: 10}, wantErr: ErrStoryNotFound},
+		{name: "Invalid story id", storyId: "../hottest", options: CommentOptions{MaxDepth: 5, MaxComments: 10}, wantErr: ErrStoryNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &LobstersAPIConnector{Url: ts.URL + "/", Client: ts.Client()}
+			got, err := c.GetComments(context.Background(), tt.storyId, tt.options)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetComments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetComments() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrNoItems reports a source answering without any item, such as a
+// scrapped page whose layout changed.
+var ErrNoItems = errors.New("no items found")
+
+// Error kinds labelling the source errors counted in sourceErrors.
+const (
+	ErrorKindNetwork = "network"
+	ErrorKindTimeout = "timeout"
+	ErrorKindStatus  = "status"
+	ErrorKindDecode  = "decode"
+	ErrorKindEmpty   = "empty"
+	ErrorKindOther   = "other"
+)
+
+var (
+	sourceFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scraper_source_fetch_duration_seconds",
+		Help:    "Time taken by the fetches of the items of every source.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20},
+	}, []string{"source"})
+	sourceItems = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scraper_source_items",
+		Help: "Number of items returned by the last successful fetch of every source.",
+	}, []string{"source"})
+	sourceItemsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_source_items_total",
+		Help: "Number of items returned by the fetches of every source.",
+	}, []string{"source"})
+	sourceErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_source_errors_total",
+		Help: "Failed or empty fetches of every source, by kind of error.",
+	}, []string{"source", "kind"})
+	sourceCommentsDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scraper_source_comments_fetch_duration_seconds",
+		Help:    "Time taken by the fetches of comment threads of every source.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20},
+	}, []string{"source"})
+	sourceCommentErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_source_comment_errors_total",
+		Help: "Failed fetches of comment threads of every source, by kind of error.",
+	}, []string{"source", "kind"})
+	sourceRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_source_retries_total",
+		Help: "Requests to every source retried after a network error or a retryable status.",
+	}, []string{"source"})
+	apiConnectorInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "scraper_api_connector_goroutines_in_flight",
+		Help: "Goroutines of the API connectors fetching items or comments.",
+	})
+	aggregatorMergeDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "scraper_aggregator_merge_duration_seconds",
+		Help:    "Time taken by the aggregator to merge, deduplicate and rank the items of its sources.",
+		Buckets: []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1},
+	})
+)
+
+// ErrorKind classifies err as one of the source error kinds.
+func ErrorKind(err error) string {
+	var statusErr StatusError
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var xmlErr *xml.SyntaxError
+	switch {
+	case errors.Is(err, ErrNoItems):
+		return ErrorKindEmpty
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorKindTimeout
+	case errors.As(err, &statusErr):
+		return ErrorKindStatus
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorKindTimeout
+	case errors.As(err, &netErr):
+		return ErrorKindNetwork
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.As(err, &xmlErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorKindDecode
+	default:
+		return ErrorKindOther
+	}
+}
@@ -5,6 +5,7 @@
 package mock_services
 
 import (
+	context "context"
 	reflect "reflect"
 
 	data "github.com/IntelligenzCodeLab/hacker-news-scraper/data"
@@ -35,16 +36,16 @@ func (m *MockRetriever) EXPECT() *MockRetrieverMockRecorder {
 }
 
 // GetItems mocks base method.
-func (m *MockRetriever) GetItems(maxItems int) ([]data.Item, error) {
+func (m *MockRetriever) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetItems", maxItems)
+	ret := m.ctrl.Call(m, "GetItems", ctx, maxItems)
 	ret0, _ := ret[0].([]data.Item)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetItems indicates an expected call of GetItems.
-func (mr *MockRetrieverMockRecorder) GetItems(maxItems interface{}) *gomock.Call {
+func (mr *MockRetrieverMockRecorder) GetItems(ctx, maxItems interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItems", reflect.TypeOf((*MockRetriever)(nil).GetItems), maxItems)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItems", reflect.TypeOf((*MockRetriever)(nil).GetItems), ctx, maxItems)
 }
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SnapshotRetriever is a Retriever serving items fetched beforehand, such as
+// the sources refreshed by a Poller, along with the time they were fetched.
+type SnapshotRetriever interface {
+	Retriever
+	GetSnapshot(ctx context.Context, maxItems int) ([]data.Item, time.Time, error)
+}
+
+// staleSnapshotIntervals is the number of poll intervals after which the
+// items of a source are no longer fresh.
+const staleSnapshotIntervals = 3
+
+// SourceSnapshot holds the outcome of the last polls of a source.
+type SourceSnapshot struct {
+	// Items are the items of the last successful poll, kept when later
+	// polls fail.
+	Items     []data.Item
+	FetchedAt time.Time
+	// Error is the error of the last poll, nil when it succeeded.
+	Error       error
+	LastAttempt time.Time
+}
+
+// Poller refreshes sources on their own interval, keeping in memory the
+// items of their last successful fetch. The sources returned by Add serve
+// those items instead of calling the source on every request.
+type Poller struct {
+	now     func() time.Time
+	mu      sync.RWMutex
+	sources []*polledSource
+}
+
+type polledSource struct {
+	key      string
+	source   SourceConnectors
+	interval time.Duration
+	maxItems int
+
+	// refreshing serialises the polls of the source, so concurrent requests
+	// arriving before the first poll trigger a single fetch.
+	refreshing sync.Mutex
+	snapshot   *SourceSnapshot
+}
+
+func NewPoller() *Poller {
+	return &Poller{now: time.Now}
+}
+
+// Add registers source to be polled every interval fetching up to maxItems,
+// returning the source serving the polled items. It must be called before
+// Start.
+func (p *Poller) Add(key string, source SourceConnectors, interval time.Duration, maxItems int) SourceConnectors {
+	polled := &polledSource{key: key, source: source, interval: interval, maxItems: maxItems}
+	p.mu.Lock()
+	p.sources = append(p.sources, polled)
+	p.mu.Unlock()
+	source.Connector = &polledRetriever{poller: p, source: polled}
+	return source
+}
+
+// Seed sets the snapshot of the source keyed key, such as the last one of a
+// replaced poller, served until the source is polled. It must be called
+// before Start.
+func (p *Poller) Seed(key string, snapshot SourceSnapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, source := range p.sources {
+		if source.key == key {
+			source.snapshot = &snapshot
+		}
+	}
+}
+
+// Start polls every source right away and then on its interval, until ctx
+// is done.
+func (p *Poller) Start(ctx context.Context) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, source := range p.sources {
+		go p.run(ctx, source)
+	}
+}
+
+func (p *Poller) run(ctx context.Context, source *polledSource) {
+	ticker := time.NewTicker(source.interval)
+	defer ticker.Stop()
+	for {
+		p.refresh(ctx, source)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Fresh reports whether the items of the source keyed key were fetched less
+// than staleSnapshotIntervals poll intervals ago.
+func (p *Poller) Fresh(key string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, source := range p.sources {
+		if source.key == key && source.snapshot != nil && !source.snapshot.FetchedAt.IsZero() {
+			return p.now().Sub(source.snapshot.FetchedAt) < staleSnapshotIntervals*source.interval
+		}
+	}
+	return false
+}
+
+// Snapshot returns the outcome of the last polls of the source keyed key.
+func (p *Poller) Snapshot(key string) (SourceSnapshot, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, source := range p.sources {
+		if source.key == key && source.snapshot != nil {
+			return *source.snapshot, true
+		}
+	}
+	return SourceSnapshot{}, false
+}
+
+func (p *Poller) refresh(ctx context.Context, source *polledSource) {
+	source.refreshing.Lock()
+	defer source.refreshing.Unlock()
+	p.poll(ctx, source)
+}
+
+func (p *Poller) poll(ctx context.Context, source *polledSource) {
+	ctx, span := tracer.Start(ctx, "Poller.poll", trace.WithAttributes(sourceAttribute(source.key)))
+	pollCtx := ctx
+	if source.source.Timeout > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, source.source.Timeout)
+		defer cancel()
+	}
+	items, err := source.source.Connector.GetItems(pollCtx, source.maxItems)
+	endSpan(span, err)
+	if ctx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	snapshot := SourceSnapshot{Error: err, LastAttempt: p.now()}
+	if err == nil {
+		snapshot.Items, snapshot.FetchedAt = items, snapshot.LastAttempt
+	} else if source.snapshot != nil {
+		slog.WarnContext(ctx, "Failed to poll source, serving previous items", "source", source.source.SourceName, "fetched_at", source.snapshot.FetchedAt, "error", err)
+		snapshot.Items, snapshot.FetchedAt = source.snapshot.Items, source.snapshot.FetchedAt
+	} else {
+		slog.WarnContext(ctx, "Failed to poll source", "source", source.source.SourceName, "error", err)
+	}
+	source.snapshot = &snapshot
+}
+
+// polledRetriever serves the items of a polled source, fetching them on
+// request while the source was never polled successfully.
+type polledRetriever struct {
+	poller *Poller
+	source *polledSource
+}
+
+func (r *polledRetriever) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	items, _, err := r.GetSnapshot(ctx, maxItems)
+	return items, err
+}
+
+func (r *polledRetriever) GetSnapshot(ctx context.Context, maxItems int) ([]data.Item, time.Time, error) {
+	snapshot, _ := r.poller.Snapshot(r.source.key)
+	if snapshot.FetchedAt.IsZero() {
+		// Polls made while waiting for the lock are not repeated.
+		lastAttempt := snapshot.LastAttempt
+		r.source.refreshing.Lock()
+		if snapshot, _ = r.poller.Snapshot(r.source.key); snapshot.LastAttempt.Equal(lastAttempt) {
+			r.poller.poll(ctx, r.source)
+			snapshot, _ = r.poller.Snapshot(r.source.key)
+		}
+		r.source.refreshing.Unlock()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+	if snapshot.FetchedAt.IsZero() {
+		return nil, time.Time{}, snapshot.Error
+	}
+	return snapshot.Items[:min(maxItems, len(snapshot.Items))], snapshot.FetchedAt, nil
+}
+
+// Unwrap returns the polled Retriever.
+func (r *polledRetriever) Unwrap() Retriever {
+	return r.source.source.Connector
+}
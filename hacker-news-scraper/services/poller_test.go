@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestPoller_Snapshots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sourceErr := errors.New("source down")
+	items := []data.Item{{Id: 1, Title: "First"}, {Id: 2, Title: "Second"}}
+	refreshed := []data.Item{{Id: 3, Title: "Third"}}
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	poller := NewPoller()
+	poller.now = func() time.Time { return now }
+	polled := poller.Add("test", SourceConnectors{SourceName: "Test", Connector: mockFetcher}, time.Minute, 50)
+	snapshots := polled.Connector.(SnapshotRetriever)
+
+	steps := []struct {
+		name          string
+		polls         bool
+		sourceItems   []data.Item
+		sourceErr     error
+		wantItems     []data.Item
+		wantFetchedAt time.Time
+		wantErr       error
+	}{
+		{name: "First request fetches source", polls: true, sourceErr: sourceErr, wantErr: sourceErr},
+		{name: "Failed first fetch is retried", polls: true, sourceItems: items, wantItems: items, wantFetchedAt: start.Add(2 * time.Minute)},
+		{name: "Snapshot is served without fetching", wantItems: items, wantFetchedAt: start.Add(2 * time.Minute)},
+		{name: "Failed poll keeps snapshot", polls: true, sourceErr: sourceErr, wantItems: items, wantFetchedAt: start.Add(2 * time.Minute)},
+		{name: "Successful poll replaces snapshot", polls: true, sourceItems: refreshed, wantItems: refreshed, wantFetchedAt: start.Add(5 * time.Minute)},
+	}
+	for i, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now = now.Add(time.Minute)
+			if step.polls {
+				mockFetcher.EXPECT().GetItems(gomock.Any(), 50).Return(step.sourceItems, step.sourceErr)
+				if i > 1 {
+					poller.refresh(context.Background(), poller.sources[0])
+				}
+			}
+			got, fetchedAt, err := snapshots.GetSnapshot(context.Background(), 10)
+			if !errors.Is(err, step.wantErr) {
+				t.Fatalf("GetSnapshot() error = %v, want %v", err, step.wantErr)
+			}
+			if !reflect.DeepEqual(got, step.wantItems) || !fetchedAt.Equal(step.wantFetchedAt) {
+				t.Errorf("GetSnapshot() got = %v fetched at %v, want %v fetched at %v", got, fetchedAt, step.wantItems, step.wantFetchedAt)
+			}
+		})
+	}
+	if got, _ := snapshots.GetItems(context.Background(), 0); len(got) != 0 {
+		t.Errorf("GetItems() got %v, want no items", got)
+	}
+}
+
+// countingRetriever counts its fetches, each one taking delay.
+type countingRetriever struct {
+	mu    sync.Mutex
+	calls int
+	delay time.Duration
+}
+
+func (r *countingRetriever) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+	time.Sleep(r.delay)
+	return []data.Item{{Id: 1, Title: "Item"}}, nil
+}
+
+func (r *countingRetriever) fetches() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func TestPoller_ConcurrentFirstRequests(t *testing.T) {
+	retriever := &countingRetriever{delay: 20 * time.Millisecond}
+	poller := NewPoller()
+	polled := poller.Add("test", SourceConnectors{SourceName: "Test", Connector: retriever}, time.Minute, 10)
+
+	var waitGroup sync.WaitGroup
+	for range 5 {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			if _, err := polled.Connector.GetItems(context.Background(), 10); err != nil {
+				t.Errorf("GetItems() error = %v", err)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	if got := retriever.fetches(); got != 1 {
+		t.Errorf("source fetched %d times, want 1", got)
+	}
+}
+
+func TestPoller_Start(t *testing.T) {
+	retriever := &countingRetriever{}
+	poller := NewPoller()
+	polled := poller.Add("test", SourceConnectors{SourceName: "Test", Connector: retriever}, 10*time.Millisecond, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	poller.Start(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for retriever.fetches() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if got := retriever.fetches(); got < 3 {
+		t.Fatalf("source polled %d times, want at least 3", got)
+	}
+
+	// Requests are served from the polled snapshot, through the aggregator too.
+	aggregator := Aggregator{Connectors: []SourceConnectors{polled}}
+	_, sourcesStatus, err := aggregator.GetItemsWithStatus(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("GetItemsWithStatus() error = %v", err)
+	}
+	snapshot, _ := poller.Snapshot("test")
+	if fetchedAt := sourcesStatus[0].FetchedAt; fetchedAt == nil || !fetchedAt.Equal(snapshot.FetchedAt) {
+		t.Errorf("source status fetched at %v, want %v", fetchedAt, snapshot.FetchedAt)
+	}
+	if _, ok := CommentsOf(polled.Connector); ok {
+		t.Errorf("CommentsOf() found comments in a source without them")
+	}
+}
+
+func TestPoller_Fresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	poller := NewPoller()
+	poller.now = func() time.Time { return now }
+	poller.Add("test", SourceConnectors{SourceName: "Test", Connector: mockFetcher}, time.Minute, 50)
+
+	steps := []struct {
+		name      string
+		elapsed   time.Duration
+		sourceErr error
+		want      bool
+	}{
+		{name: "Failed first poll", sourceErr: errors.New("source down"), want: false},
+		{name: "Successful poll", want: true},
+		{name: "Failed poll keeps recent items", elapsed: 2 * time.Minute, sourceErr: errors.New("source down"), want: true},
+		{name: "Failed polls outdate items", elapsed: time.Minute, sourceErr: errors.New("source down"), want: false},
+	}
+	if poller.Fresh("test") {
+		t.Errorf("Fresh() = true before polling")
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now = now.Add(step.elapsed)
+			mockFetcher.EXPECT().GetItems(gomock.Any(), 50).Return([]data.Item{{Id: 1, Title: "First"}}, step.sourceErr)
+			poller.refresh(context.Background(), poller.sources[0])
+			if got := poller.Fresh("test"); got != step.want {
+				t.Errorf("Fresh() = %v, want %v", got, step.want)
+			}
+		})
+	}
+	if poller.Fresh("unknown") {
+		t.Errorf("Fresh() = true for an unknown source")
+	}
+}
+
+func TestPoller_Seed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	seeded := SourceSnapshot{Items: []data.Item{{Id: 1, Title: "Seeded"}}, FetchedAt: now.Add(-time.Minute), LastAttempt: now.Add(-time.Minute)}
+	poller := NewPoller()
+	poller.now = func() time.Time { return now }
+	polled := poller.Add("test", SourceConnectors{SourceName: "Test", Connector: mockFetcher}, time.Minute, 50)
+	poller.Seed("test", seeded)
+
+	got, fetchedAt, err := polled.Connector.(SnapshotRetriever).GetSnapshot(context.Background(), 10)
+	if err != nil || !reflect.DeepEqual(got, seeded.Items) || !fetchedAt.Equal(seeded.FetchedAt) {
+		t.Errorf("GetSnapshot() got = %v fetched at %v, %v, want the seeded items without fetching", got, fetchedAt, err)
+	}
+	if !poller.Fresh("test") {
+		t.Errorf("Fresh() = false for a recently seeded source")
+	}
+
+	mockFetcher.EXPECT().GetItems(gomock.Any(), 50).Return(nil, errors.New("source down"))
+	poller.refresh(context.Background(), poller.sources[0])
+	if snapshot, _ := poller.Snapshot("test"); !reflect.DeepEqual(snapshot.Items, seeded.Items) {
+		t.Errorf("Snapshot() after a failed poll got = %v, want the seeded items", snapshot.Items)
+	}
+}
+
+// generationRetriever lists maxItems items of a new generation on every
+// fetch, the generation being the score of all of them.
+type generationRetriever struct {
+	generation atomic.Int32
+}
+
+func (r *generationRetriever) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	generation := int(r.generation.Add(1))
+	items := make([]data.Item, maxItems)
+	for i := range items {
+		items[i] = data.Item{Id: data.ItemId(generation*maxItems + i), Title: fmt.Sprintf("Item %d", i), Score: generation}
+	}
+	return items, nil
+}
+
+func TestPoller_ReplacesSnapshotsWhole(t *testing.T) {
+	retriever := &generationRetriever{}
+	poller := NewPoller()
+	polled := poller.Add("test", SourceConnectors{SourceName: "Test", Connector: retriever}, time.Minute, 20)
+	snapshots := polled.Connector.(SnapshotRetriever)
+	poller.refresh(context.Background(), poller.sources[0])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		for ctx.Err() == nil {
+			poller.refresh(ctx, poller.sources[0])
+		}
+	}()
+	lastGeneration := 0
+	for range 1000 {
+		got, _, err := snapshots.GetSnapshot(context.Background(), 20)
+		if err != nil {
+			t.Fatalf("GetSnapshot() error = %v", err)
+		}
+		if len(got) != 20 {
+			t.Fatalf("GetSnapshot() got %d items, want 20", len(got))
+		}
+		for _, item := range got {
+			if item.Score != got[0].Score {
+				t.Fatalf("GetSnapshot() mixed items of polls %d and %d", got[0].Score, item.Score)
+			}
+		}
+		if got[0].Score < lastGeneration {
+			t.Fatalf("GetSnapshot() served poll %d after poll %d", got[0].Score, lastGeneration)
+		}
+		lastGeneration = got[0].Score
+	}
+	cancel()
+	waitGroup.Wait()
+}
+
+func TestPoller_EmptyPollIsNoSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFetcher := mock_services.NewMockRetriever(ctrl)
+	mockFetcher.EXPECT().GetItems(gomock.Any(), 50).Return([]data.Item{}, nil)
+	poller := NewPoller()
+	poller.Add("test", SourceConnectors{SourceName: "Test", Connector: NewInstrumentedRetriever("empty poll", mockFetcher)}, time.Minute, 50)
+	poller.refresh(context.Background(), poller.sources[0])
+
+	if snapshot, _ := poller.Snapshot("test"); !errors.Is(snapshot.Error, ErrNoItems) || !snapshot.FetchedAt.IsZero() {
+		t.Errorf("Snapshot() = %+v, want a failed poll", snapshot)
+	}
+	if poller.Fresh("test") {
+		t.Errorf("Fresh() = true after an empty poll")
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+const DefaultRanking = "title"
+
+// Ranker orders items, returning a new slice and leaving its input untouched.
+type Ranker interface {
+	Rank(items []data.Item) []data.Item
+}
+
+// Rankers holds the built-in rankers by the name used in the sort parameter.
+var Rankers = map[string]Ranker{
+	DefaultRanking: TitleWordsRanker{MaxShortWords: 5},
+	"score":        ScoreRanker{},
+	"comments":     CommentsRanker{},
+	"recency":      RecencyRanker{},
+	"gravity":      GravityRanker{Gravity: 1.8},
+}
+
+func RankerByName(name string) (Ranker, error) {
+	if name == "" {
+		name = DefaultRanking
+	}
+	ranker, ok := Rankers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown ranking %q, available rankings: %s", name, strings.Join(RankerNames(), ", "))
+	}
+	return ranker, nil
+}
+
+func RankerNames() []string {
+	names := make([]string, 0, len(Rankers))
+	for name := range Rankers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TitleWordsRanker puts first the items with more than MaxShortWords words in
+// their title sorted by comments, followed by the rest sorted by score.
+type TitleWordsRanker struct {
+	MaxShortWords int
+}
+
+func (r TitleWordsRanker) Rank(items []data.Item) []data.Item {
+	longTitleItems := make([]data.Item, 0)
+	shortTitleItems := make([]data.Item, 0)
+	for _, item := range items {
+		if len(strings.Fields(item.Title)) > r.MaxShortWords {
+			longTitleItems = append(longTitleItems, item)
+		} else {
+			shortTitleItems = append(shortTitleItems, item)
+		}
+	}
+
+	slices.SortStableFunc(longTitleItems, func(a, b data.Item) int {
+		return cmp.Compare(b.Descendants, a.Descendants)
+	})
+
+	slices.SortStableFunc(shortTitleItems, func(a, b data.Item) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return append(longTitleItems, shortTitleItems...)
+}
+
+type ScoreRanker struct{}
+
+func (ScoreRanker) Rank(items []data.Item) []data.Item {
+	return sortedBy(items, func(item data.Item) float64 { return float64(item.Score) })
+}
+
+type CommentsRanker struct{}
+
+func (CommentsRanker) Rank(items []data.Item) []data.Item {
+	return sortedBy(items, func(item data.Item) float64 { return float64(item.Descendants) })
+}
+
+// RecencyRanker puts the most recently submitted items first.
+type RecencyRanker struct{}
+
+func (RecencyRanker) Rank(items []data.Item) []data.Item {
+	return sortedBy(items, func(item data.Item) float64 { return float64(item.Time) })
+}
+
+// GravityRanker applies the Hacker News front page formula
+// (score-1)/(age+2)^gravity, age being the item age in hours.
+type GravityRanker struct {
+	Gravity float64
+	// Now returns the current time. time.Now is used when nil.
+	Now func() time.Time
+}
+
+func (r GravityRanker) Rank(items []data.Item) []data.Item {
+	now := time.Now()
+	if r.Now != nil {
+		now = r.Now()
+	}
+	return sortedBy(items, func(item data.Item) float64 {
+		ageHours := max(now.Sub(time.Unix(int64(item.Time), 0)).Hours(), 0)
+		return float64(item.Score-1) / math.Pow(ageHours+2, r.Gravity)
+	})
+}
+
+// sortedBy returns a copy of items sorted by descending key.
+func sortedBy(items []data.Item, key func(item data.Item) float64) []data.Item {
+	sorted := slices.Clone(items)
+	slices.SortStableFunc(sorted, func(a, b data.Item) int {
+		return cmp.Compare(key(b), key(a))
+	})
+	return sorted
+}
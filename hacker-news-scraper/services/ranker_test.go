@@ -0,0 +1,67 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+func TestRankers_Rank(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	hoursAgo := func(hours int) int { return int(now.Add(-time.Duration(hours) * time.Hour).Unix()) }
+	longOld := data.Item{Id: 1, Title: "A title with more than five words", Descendants: 10, Score: 300, Time: hoursAgo(20)}
+	longNew := data.Item{Id: 2, Title: "Another title having more than five words", Descendants: 40, Score: 20, Time: hoursAgo(1)}
+	fiveWords := data.Item{Id: 3, Title: "Exactly five words in title", Descendants: 90, Score: 100, Time: hoursAgo(3)}
+	short := data.Item{Id: 4, Title: "Short", Descendants: 5, Score: 150, Time: hoursAgo(2)}
+	items := []data.Item{longOld, longNew, fiveWords, short}
+
+	tests := []struct {
+		name   string
+		ranker Ranker
+		want   []data.Item
+	}{
+		{name: "Title words", ranker: Rankers[DefaultRanking], want: []data.Item{longNew, longOld, short, fiveWords}},
+		{name: "Score", ranker: ScoreRanker{}, want: []data.Item{longOld, short, fiveWords, longNew}},
+		{name: "Comments", ranker: CommentsRanker{}, want: []data.Item{fiveWords, longNew, longOld, short}},
+		{name: "Recency", ranker: RecencyRanker{}, want: []data.Item{longNew, short, fiveWords, longOld}},
+		{name: "Gravity", ranker: GravityRanker{Gravity: 1.8, Now: func() time.Time { return now }}, want: []data.Item{short, fiveWords, longNew, longOld}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.ranker.Rank(items)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rank() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(items, []data.Item{longOld, longNew, fiveWords, short}) {
+				t.Errorf("Rank() modified its input: %v", items)
+			}
+		})
+	}
+}
+
+func TestRankerByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		ranking string
+		want    Ranker
+		wantErr bool
+	}{
+		{name: "Default ranking", ranking: "", want: Rankers[DefaultRanking]},
+		{name: "Known ranking", ranking: "gravity", want: Rankers["gravity"]},
+		{name: "Unknown ranking", ranking: "random", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RankerByName(tt.ranking)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RankerByName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RankerByName() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strconv"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+// RankingThresholds are the scores and comment counts whose crossing by a
+// ranked item is reported.
+type RankingThresholds struct {
+	Score    []int
+	Comments []int
+}
+
+var DefaultRankingThresholds = RankingThresholds{Score: []int{100, 250, 500, 1000}, Comments: []int{50, 100, 250, 500}}
+
+// ItemId is the identifier of an item in responses, the short id of the
+// sources having one.
+func ItemId(item data.Item) string {
+	if item.ShortId != "" {
+		return item.ShortId
+	}
+	return strconv.Itoa(int(item.Id))
+}
+
+// itemSource returns the name of the first source listing item, empty when
+// the aggregator did not keep track of it.
+func itemSource(item data.Item) string {
+	if len(item.Sources) == 0 {
+		return ""
+	}
+	return item.Sources[0].Source
+}
+
+// rankingKey identifies item in rankings, the same id being possibly used
+// by different sources.
+func rankingKey(item data.Item) string {
+	return itemSource(item) + "/" + ItemId(item)
+}
+
+// DiffRankings returns the changes from previous to current, two successive
+// rankings: items entering, leaving or moving, then items crossing one of
+// the thresholds upwards. Events follow the current ranking order, items
+// that left coming last.
+func DiffRankings(previous []data.Item, current []data.Item, thresholds RankingThresholds) []data.RankingEvent {
+	previousRanks := make(map[string]int, len(previous))
+	for i, item := range previous {
+		previousRanks[rankingKey(item)] = i + 1
+	}
+
+	events := make([]data.RankingEvent, 0)
+	currentKeys := make(map[string]bool, len(current))
+	for i, item := range current {
+		key := rankingKey(item)
+		currentKeys[key] = true
+		rank, previousRank := i+1, previousRanks[key]
+		switch {
+		case previousRank == 0:
+			events = append(events, rankingEvent(data.ItemEntered, item, rank, 0, 0))
+		case previousRank != rank:
+			events = append(events, rankingEvent(data.ItemMoved, item, rank, previousRank, 0))
+		}
+		if previousRank == 0 {
+			continue
+		}
+		before := previous[previousRank-1]
+		for _, threshold := range thresholds.Score {
+			if before.Score < threshold && item.Score >= threshold {
+				events = append(events, rankingEvent(data.ScoreThreshold, item, rank, previousRank, threshold))
+			}
+		}
+		for _, threshold := range thresholds.Comments {
+			if before.Descendants < threshold && item.Descendants >= threshold {
+				events = append(events, rankingEvent(data.CommentsThreshold, item, rank, previousRank, threshold))
+			}
+		}
+	}
+	for i, item := range previous {
+		if !currentKeys[rankingKey(item)] {
+			events = append(events, rankingEvent(data.ItemLeft, item, 0, i+1, 0))
+		}
+	}
+	return events
+}
+
+func rankingEvent(eventType data.RankingEventType, item data.Item, rank int, previousRank int, threshold int) data.RankingEvent {
+	return data.RankingEvent{Type: eventType, Source: itemSource(item), Id: ItemId(item), Title: item.Title, Url: item.Url, Rank: rank, PreviousRank: previousRank,
+		Score: item.Score, Comments: item.Descendants, Threshold: threshold}
+}
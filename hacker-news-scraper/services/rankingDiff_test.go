@@ -0,0 +1,56 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+func TestDiffRankings(t *testing.T) {
+	first := data.Item{Id: 1, Title: "First", Url: "https://example.com/1", Score: 90, Descendants: 40}
+	second := data.Item{Id: 2, Title: "Second", Url: "https://example.com/2", Score: 80, Descendants: 10}
+	third := data.Item{Id: 3, ShortId: "abc123", Title: "Third", Url: "https://example.com/3", Score: 20}
+	climbing := second
+	climbing.Score, climbing.Descendants = 260, 120
+	hnItem := data.Item{Id: 7, Title: "Hacker News", Sources: []data.ItemSource{{Source: "Hacker News", Id: 7}}}
+	lobstersItem := data.Item{Id: 7, Title: "Lobsters", Sources: []data.ItemSource{{Source: "Lobsters", Id: 7}}}
+	thresholds := RankingThresholds{Score: []int{100, 250}, Comments: []int{100}}
+
+	tests := []struct {
+		name     string
+		previous []data.Item
+		current  []data.Item
+		want     []data.RankingEvent
+	}{
+		{name: "Unchanged ranking", previous: []data.Item{first, second}, current: []data.Item{first, second}, want: []data.RankingEvent{}},
+		{name: "Entering and leaving items", previous: []data.Item{first, second}, current: []data.Item{first, third}, want: []data.RankingEvent{
+			{Type: data.ItemEntered, Id: "abc123", Title: "Third", Url: "https://example.com/3", Rank: 2, Score: 20},
+			{Type: data.ItemLeft, Id: "2", Title: "Second", Url: "https://example.com/2", PreviousRank: 2, Score: 80, Comments: 10},
+		}},
+		{name: "Moving items crossing thresholds", previous: []data.Item{first, second}, current: []data.Item{climbing, first}, want: []data.RankingEvent{
+			{Type: data.ItemMoved, Id: "2", Title: "Second", Url: "https://example.com/2", Rank: 1, PreviousRank: 2, Score: 260, Comments: 120},
+			{Type: data.ScoreThreshold, Id: "2", Title: "Second", Url: "https://example.com/2", Rank: 1, PreviousRank: 2, Score: 260, Comments: 120, Threshold: 100},
+			{Type: data.ScoreThreshold, Id: "2", Title: "Second", Url: "https://example.com/2", Rank: 1, PreviousRank: 2, Score: 260, Comments: 120, Threshold: 250},
+			{Type: data.CommentsThreshold, Id: "2", Title: "Second", Url: "https://example.com/2", Rank: 1, PreviousRank: 2, Score: 260, Comments: 120, Threshold: 100},
+			{Type: data.ItemMoved, Id: "1", Title: "First", Url: "https://example.com/1", Rank: 2, PreviousRank: 1, Score: 90, Comments: 40},
+		}},
+		{name: "Same id in different sources", previous: []data.Item{hnItem, lobstersItem}, current: []data.Item{lobstersItem, hnItem}, want: []data.RankingEvent{
+			{Type: data.ItemMoved, Source: "Lobsters", Id: "7", Title: "Lobsters", Rank: 1, PreviousRank: 2},
+			{Type: data.ItemMoved, Source: "Hacker News", Id: "7", Title: "Hacker News", Rank: 2, PreviousRank: 1},
+		}},
+		{name: "Same id leaving one source", previous: []data.Item{hnItem, lobstersItem}, current: []data.Item{hnItem}, want: []data.RankingEvent{
+			{Type: data.ItemLeft, Source: "Lobsters", Id: "7", Title: "Lobsters", PreviousRank: 2},
+		}},
+		{name: "Entering items do not cross thresholds", previous: []data.Item{first}, current: []data.Item{first, climbing}, want: []data.RankingEvent{
+			{Type: data.ItemEntered, Id: "2", Title: "Second", Url: "https://example.com/2", Rank: 2, Score: 260, Comments: 120},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffRankings(tt.previous, tt.current, thresholds); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffRankings() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
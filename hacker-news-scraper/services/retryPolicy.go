@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, the first one included.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter is the fraction of every backoff delay that is randomized, from 0 to 1.
+	Jitter            float64
+	RetryableStatuses []int
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:       3,
+	BaseDelay:         200 * time.Millisecond,
+	MaxDelay:          5 * time.Second,
+	Jitter:            0.5,
+	RetryableStatuses: []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+// backoff returns the delay before the given retry (starting at 1), growing
+// exponentially from BaseDelay, jittered, and never over MaxDelay.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << (retry - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		jitter := time.Duration(float64(delay) * p.Jitter * rand.Float64())
+		delay = delay - time.Duration(float64(delay)*p.Jitter) + 2*jitter
+	}
+	return min(delay, p.MaxDelay)
+}
+
+func (p RetryPolicy) retryableStatus(statusCode int) bool {
+	return slices.Contains(p.RetryableStatuses, statusCode)
+}
+
+// RetryTransport retries idempotent requests failing with a network error or
+// a retryable status, waiting between attempts as the policy states or as the
+// server asks through Retry-After.
+type RetryTransport struct {
+	// Base performs the requests. http.DefaultTransport is used when nil.
+	Base   http.RoundTripper
+	Policy RetryPolicy
+	// Source is the key of the retried source in logs and metrics.
+	Source string
+}
+
+// WithRetries returns a copy of client whose requests are retried by policy.
+func WithRetries(client *http.Client, policy RetryPolicy, source string) *http.Client {
+	retryClient := *client
+	retryClient.Transport = &RetryTransport{Base: client.Transport, Policy: policy, Source: source}
+	return &retryClient
+}
+
+// CloseIdleConnections closes the idle connections of the base transport.
+func (t *RetryTransport) CloseIdleConnections() {
+	if t.Base != nil {
+		closeIdleConnections(t.Base)
+	}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead || req.Body != nil && req.Body != http.NoBody {
+		return base.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if attempt >= t.Policy.MaxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			delay = t.Policy.backoff(attempt)
+		case t.Policy.retryableStatus(resp.StatusCode):
+			delay = t.Policy.backoff(attempt)
+			if serverDelay, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if serverDelay > t.Policy.MaxDelay {
+					return resp, nil
+				}
+				delay = serverDelay
+			}
+		default:
+			return resp, nil
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		sourceRetries.WithLabelValues(t.Source).Inc()
+		slog.WarnContext(req.Context(), "Retrying request", "method", req.Method, "url", req.URL.String(), "source", t.Source, "attempt", attempt+1, "max_attempts", t.Policy.MaxAttempts, "delay", delay, "reason", reason)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, errors.Join(req.Context().Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
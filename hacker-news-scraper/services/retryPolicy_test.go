@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRetryTransport_RoundTrip(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, RetryableStatuses: []int{http.StatusServiceUnavailable}}
+	tests := []struct {
+		name         string
+		failures     int
+		failStatus   int
+		retryAfter   string
+		wantStatus   int
+		wantAttempts int32
+	}{
+		{name: "No failure", failures: 0, wantStatus: http.StatusOK, wantAttempts: 1},
+		{name: "Recovers after retryable failures", failures: 2, failStatus: http.StatusServiceUnavailable, wantStatus: http.StatusOK, wantAttempts: 3},
+		{name: "Gives up after max attempts", failures: 5, failStatus: http.StatusServiceUnavailable, wantStatus: http.StatusServiceUnavailable, wantAttempts: 3},
+		{name: "Does not retry non retryable status", failures: 5, failStatus: http.StatusNotFound, wantStatus: http.StatusNotFound, wantAttempts: 1},
+		{name: "Honours short Retry-After", failures: 1, failStatus: http.StatusServiceUnavailable, retryAfter: "0", wantStatus: http.StatusOK, wantAttempts: 2},
+		{name: "Gives up on Retry-After beyond max delay", failures: 1, failStatus: http.StatusServiceUnavailable, retryAfter: "120", wantStatus: http.StatusServiceUnavailable, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(attempts.Add(1)) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.failStatus)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer ts.Close()
+
+			source := "retry-" + tt.name
+			retries := testutil.ToFloat64(sourceRetries.WithLabelValues(source))
+			client := WithRetries(ts.Client(), policy, source)
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Do() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("Do() attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			if got := testutil.ToFloat64(sourceRetries.WithLabelValues(source)) - retries; got != float64(tt.wantAttempts-1) {
+				t.Errorf("retries counted = %v, want %d", got, tt.wantAttempts-1)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "Empty", value: "", wantOk: false},
+		{name: "Seconds", value: "3", want: 3 * time.Second, wantOk: true},
+		{name: "HTTP date", value: "Sat, 01 Jun 2024 12:00:10 GMT", want: 10 * time.Second, wantOk: true},
+		{name: "Past HTTP date", value: "Sat, 01 Jun 2024 11:00:00 GMT", want: 0, wantOk: true},
+		{name: "Invalid", value: "soon", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.value, now)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("retryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second, Jitter: 0.5}
+	tests := []struct {
+		name    string
+		retry   int
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "First retry", retry: 1, wantMin: 500 * time.Millisecond, wantMax: 1500 * time.Millisecond},
+		{name: "Third retry", retry: 3, wantMin: 2 * time.Second, wantMax: 5 * time.Second},
+		{name: "Capped retry", retry: 10, wantMin: 2500 * time.Millisecond, wantMax: 5 * time.Second},
+		{name: "Overflowing retry", retry: 100, wantMin: 2500 * time.Millisecond, wantMax: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 1000 {
+				if got := policy.backoff(tt.retry); got < tt.wantMin || got > tt.wantMax {
+					t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.retry, got, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
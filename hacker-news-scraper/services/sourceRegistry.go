@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SourceRegistry holds the sources that can be requested by key, such as
+// "hn" or "lobsters", in registration order.
+type SourceRegistry struct {
+	keys    []string
+	sources map[string]SourceConnectors
+}
+
+type UnknownSourceError struct {
+	Key string
+}
+
+func (e UnknownSourceError) Error() string { return fmt.Sprintf("unknown source %q", e.Key) }
+
+func NewSourceRegistry() *SourceRegistry {
+	return &SourceRegistry{sources: make(map[string]SourceConnectors)}
+}
+
+func (r *SourceRegistry) Register(key string, source SourceConnectors) error {
+	if key == "" || strings.Contains(key, ",") {
+		return fmt.Errorf("invalid source key %q", key)
+	}
+	if _, ok := r.sources[key]; ok {
+		return fmt.Errorf("source %q already registered", key)
+	}
+	r.keys = append(r.keys, key)
+	r.sources[key] = source
+	return nil
+}
+
+func (r *SourceRegistry) Keys() []string {
+	return append([]string(nil), r.keys...)
+}
+
+func (r *SourceRegistry) Get(key string) (SourceConnectors, bool) {
+	source, ok := r.sources[key]
+	return source, ok
+}
+
+// Lookup returns the sources registered under keys, in the given order and
+// without repetitions.
+func (r *SourceRegistry) Lookup(keys []string) ([]SourceConnectors, error) {
+	connectors := make([]SourceConnectors, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		source, ok := r.sources[key]
+		if !ok {
+			return nil, UnknownSourceError{Key: key}
+		}
+		if !seen[key] {
+			seen[key] = true
+			connectors = append(connectors, source)
+		}
+	}
+	return connectors, nil
+}
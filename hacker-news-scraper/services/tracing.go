@@ -0,0 +1,58 @@
+package services
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the services, exported through the tracer
+// provider installed by the application.
+var tracer = otel.Tracer("github.com/IntelligenzCodeLab/hacker-news-scraper/services")
+
+// sourceAttribute identifies the source of the spans fetching its items.
+func sourceAttribute(source string) attribute.KeyValue {
+	return attribute.String("scraper.source", source)
+}
+
+// endSpan ends span, recording err as its failure.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TracedTransport traces the requests performed by base, propagating their
+// trace context to the servers. http.DefaultTransport is used when base is
+// nil.
+func TracedTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return tracedTransport{RoundTripper: otelhttp.NewTransport(base), base: base}
+}
+
+// tracedTransport lets the idle connections of the traced transport be
+// closed, which otelhttp does not.
+type tracedTransport struct {
+	http.RoundTripper
+	base http.RoundTripper
+}
+
+func (t tracedTransport) CloseIdleConnections() {
+	closeIdleConnections(t.base)
+}
+
+// closeIdleConnections closes the idle connections of transport when it
+// keeps any.
+func closeIdleConnections(transport http.RoundTripper) {
+	if closer, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
@@ -1,21 +1,44 @@
 package services
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"net/http"
+	"strconv"
+
 	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
 	"github.com/gocolly/colly"
-	"strconv"
 )
 
 type WebScrapperConnector struct {
 	Url string
+	// Transport performs the page requests. http.DefaultTransport is used when nil.
+	Transport http.RoundTripper
 }
 
-func (ws *WebScrapperConnector) GetItems(maxItems int) ([]data.Item, error) {
+// contextTransport binds every request issued by a colly collector to ctx,
+// as colly does not accept a context on Visit.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+func (ws *WebScrapperConnector) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	items := make([]data.Item, 0)
 	fetchedItems := 0
 	collector := colly.NewCollector()
+	transport := ws.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	collector.WithTransport(&contextTransport{ctx: ctx, base: transport})
 	collector.OnHTML("ol.stories.list", func(elemList *colly.HTMLElement) {
 		elemList.ForEach("li.story", func(i int, specListElement *colly.HTMLElement) {
 			if i >= maxItems {
@@ -31,10 +54,12 @@ func (ws *WebScrapperConnector) GetItems(maxItems int) ([]data.Item, error) {
 		})
 	})
 	err := collector.Visit(ws.Url)
-	if err != nil {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	} else if err != nil {
 		return nil, err
 	} else if fetchedItems == 0 {
-		return nil, errors.New("no items found in scrapping")
+		return nil, fmt.Errorf("%w in scrapping", ErrNoItems)
 	}
 	return items, nil
 }
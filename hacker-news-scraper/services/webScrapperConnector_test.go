@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -89,7 +91,7 @@ func TestWebScrapperConnector_GetItems(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &WebScrapperConnector{Url: ts.URL + tt.fields.Url}
-			got, err := c.GetItems(tt.fields.MaxResults)
+			got, err := c.GetItems(context.Background(), tt.fields.MaxResults)
 			if err != nil {
 				switch {
 				case !tt.wantErr:
@@ -114,3 +116,15 @@ func getItemFromMock(t *testing.T, itemMock string, item *data.Item) {
 		t.Fatalf("Error unmarshaling JSON: %v", err)
 	}
 }
+
+func TestWebScrapperConnector_GetItemsCancelled(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := &WebScrapperConnector{Url: ts.URL + "/test1"}
+	if _, err := c.GetItems(ctx, 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetItems() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,196 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	bolt "go.etcd.io/bbolt"
+)
+
+var frontPagesBucket = []byte("front_pages")
+var itemsBucket = []byte("items")
+
+// BoltStore is a Store kept in a single bbolt file. Front pages are stored
+// per source keyed by fetch time, and item snapshots per source and item,
+// also keyed by fetch time so time ranges are read with a cursor seek.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{frontPagesBucket, itemsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Record(source string, fetchedAt time.Time, items []data.Item) error {
+	fetchedAt = fetchedAt.UTC()
+	key := timeKey(fetchedAt)
+	snapshots := snapshots(fetchedAt, items)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		frontPages, err := tx.Bucket(frontPagesBucket).CreateBucketIfNotExists([]byte(source))
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(snapshots)
+		if err != nil {
+			return err
+		}
+		if err := frontPages.Put(key, value); err != nil {
+			return err
+		}
+
+		sourceItems, err := tx.Bucket(itemsBucket).CreateBucketIfNotExists([]byte(source))
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range snapshots {
+			itemSnapshots, err := sourceItems.CreateBucketIfNotExists([]byte(snapshot.Id))
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(snapshot)
+			if err != nil {
+				return err
+			}
+			if err := itemSnapshots.Put(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) History(source string, itemId string, from time.Time, to time.Time) ([]data.ItemSnapshot, error) {
+	history := make([]data.ItemSnapshot, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		sourceItems := tx.Bucket(itemsBucket).Bucket([]byte(source))
+		if sourceItems == nil {
+			return ErrNotFound
+		}
+		itemSnapshots := sourceItems.Bucket([]byte(itemId))
+		if itemSnapshots == nil {
+			return ErrNotFound
+		}
+		cursor := itemSnapshots.Cursor()
+		end := timeKey(to.UTC())
+		for key, value := cursor.Seek(timeKey(from.UTC())); key != nil && bytes.Compare(key, end) <= 0; key, value = cursor.Next() {
+			var snapshot data.ItemSnapshot
+			if err := json.Unmarshal(value, &snapshot); err != nil {
+				return err
+			}
+			history = append(history, snapshot)
+		}
+		return nil
+	})
+	return history, err
+}
+
+func (s *BoltStore) FrontPage(source string, at time.Time) (data.FrontPage, error) {
+	frontPage := data.FrontPage{Source: source}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		frontPages := tx.Bucket(frontPagesBucket).Bucket([]byte(source))
+		if frontPages == nil {
+			return ErrNotFound
+		}
+		cursor := frontPages.Cursor()
+		at := timeKey(at.UTC())
+		key, value := cursor.Seek(at)
+		if key == nil || !bytes.Equal(key, at) {
+			// Seek stops at the first fetch after at, the one wanted is the previous.
+			key, value = cursor.Prev()
+		}
+		if key == nil {
+			return ErrNotFound
+		}
+		frontPage.FetchedAt = time.Unix(0, int64(binary.BigEndian.Uint64(key))).UTC()
+		return json.Unmarshal(value, &frontPage.Items)
+	})
+	return frontPage, err
+}
+
+func (s *BoltStore) Prune(before time.Time) error {
+	end := timeKey(before.UTC())
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(frontPagesBucket).ForEachBucket(func(source []byte) error {
+			_, err := pruneBucket(tx.Bucket(frontPagesBucket).Bucket(source), end)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(itemsBucket).ForEachBucket(func(source []byte) error {
+			sourceItems := tx.Bucket(itemsBucket).Bucket(source)
+			// Buckets cannot be deleted while iterated, emptied items are
+			// deleted afterwards.
+			var emptied [][]byte
+			err := sourceItems.ForEachBucket(func(itemId []byte) error {
+				empty, err := pruneBucket(sourceItems.Bucket(itemId), end)
+				if empty {
+					emptied = append(emptied, itemId)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			for _, itemId := range emptied {
+				if err := sourceItems.DeleteBucket(itemId); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// pruneBucket deletes the keys of bucket sorting before end, reporting
+// whether the bucket is left empty.
+func pruneBucket(bucket *bolt.Bucket, end []byte) (bool, error) {
+	cursor := bucket.Cursor()
+	key, _ := cursor.First()
+	// Moving on from a deleted key can skip the next one, the first key
+	// is read again instead.
+	for ; key != nil && bytes.Compare(key, end) < 0; key, _ = cursor.First() {
+		if err := cursor.Delete(); err != nil {
+			return false, err
+		}
+	}
+	return key == nil, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// timeKey encodes t so that keys sort in time order. Times out of the range
+// of UnixNano, such as the zero time, are clamped to it.
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	switch {
+	case t.Before(time.Unix(0, 0)):
+	case t.After(time.Unix(0, math.MaxInt64)):
+		binary.BigEndian.PutUint64(key, math.MaxInt64)
+	default:
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	return key
+}
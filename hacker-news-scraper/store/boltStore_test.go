@@ -0,0 +1,162 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+)
+
+func openTestStore(t *testing.T) *BoltStore {
+	itemStore, err := OpenBoltStore(filepath.Join(t.TempDir(), "items.db"))
+	if err != nil {
+		t.Fatalf("OpenBoltStore() error = %v", err)
+	}
+	t.Cleanup(func() { itemStore.Close() })
+	return itemStore
+}
+
+func TestBoltStore(t *testing.T) {
+	itemStore := openTestStore(t)
+	morning := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	noon := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	story := data.Item{Id: 1, Title: "Climbing story", Url: "https://example.com/climbing", Score: 10, Descendants: 2}
+	other := data.Item{Id: 2, Title: "Other story", Url: "https://example.com/other", Score: 50, Descendants: 20}
+	lobstersStory := data.Item{Id: 1490477354, ShortId: "one3oq", Title: "Lobsters story", Score: 5}
+	climbed := story
+	climbed.Score, climbed.Descendants = 120, 45
+	for _, record := range []struct {
+		source    string
+		fetchedAt time.Time
+		items     []data.Item
+	}{
+		{source: "hn", fetchedAt: morning, items: []data.Item{other, story}},
+		{source: "hn", fetchedAt: noon, items: []data.Item{climbed, other}},
+		{source: "lobsters", fetchedAt: noon, items: []data.Item{lobstersStory}},
+	} {
+		if err := itemStore.Record(record.source, record.fetchedAt, record.items); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	morningSnapshot := data.ItemSnapshot{Id: "1", FetchedAt: morning, Rank: 2, Title: "Climbing story", Url: "https://example.com/climbing", Score: 10, Comments: 2}
+	noonSnapshot := data.ItemSnapshot{Id: "1", FetchedAt: noon, Rank: 1, Title: "Climbing story", Url: "https://example.com/climbing", Score: 120, Comments: 45}
+	historyTests := []struct {
+		name    string
+		source  string
+		itemId  string
+		from    time.Time
+		to      time.Time
+		want    []data.ItemSnapshot
+		wantErr error
+	}{
+		{name: "Whole history", source: "hn", itemId: "1", to: noon, want: []data.ItemSnapshot{morningSnapshot, noonSnapshot}},
+		{name: "History range", source: "hn", itemId: "1", from: morning.Add(time.Minute), to: noon.Add(time.Hour), want: []data.ItemSnapshot{noonSnapshot}},
+		{name: "Empty range", source: "hn", itemId: "1", from: noon.Add(time.Minute), to: noon.Add(time.Hour), want: []data.ItemSnapshot{}},
+		{name: "Short id", source: "lobsters", itemId: "one3oq", to: noon,
+			want: []data.ItemSnapshot{{Id: "one3oq", FetchedAt: noon, Rank: 1, Title: "Lobsters story", Score: 5}}},
+		{name: "Unknown item", source: "hn", itemId: "3", to: noon, wantErr: ErrNotFound},
+		{name: "Unknown source", source: "feed", itemId: "1", to: noon, wantErr: ErrNotFound},
+	}
+	for _, tt := range historyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := itemStore.History(tt.source, tt.itemId, tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("History() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("History() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	frontPageTests := []struct {
+		name          string
+		source        string
+		at            time.Time
+		wantFetchedAt time.Time
+		wantIds       []string
+		wantErr       error
+	}{
+		{name: "Latest front page", source: "hn", at: noon.Add(time.Hour), wantFetchedAt: noon, wantIds: []string{"1", "2"}},
+		{name: "Front page at fetch time", source: "hn", at: noon, wantFetchedAt: noon, wantIds: []string{"1", "2"}},
+		{name: "Earlier front page", source: "hn", at: noon.Add(-time.Second), wantFetchedAt: morning, wantIds: []string{"2", "1"}},
+		{name: "Before first fetch", source: "hn", at: morning.Add(-time.Second), wantErr: ErrNotFound},
+		{name: "Unknown source", source: "feed", at: noon, wantErr: ErrNotFound},
+	}
+	for _, tt := range frontPageTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := itemStore.FrontPage(tt.source, tt.at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FrontPage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			gotIds := make([]string, len(got.Items))
+			for i, item := range got.Items {
+				gotIds[i] = item.Id
+			}
+			if !got.FetchedAt.Equal(tt.wantFetchedAt) || !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("FrontPage() got fetch %v with %v, want fetch %v with %v", got.FetchedAt, gotIds, tt.wantFetchedAt, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestBoltStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.db")
+	itemStore, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("OpenBoltStore() error = %v", err)
+	}
+	fetchedAt := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	if err := itemStore.Record("hn", fetchedAt, []data.Item{{Id: 1, Title: "Kept story"}}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	itemStore.Close()
+
+	itemStore, err = OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("OpenBoltStore() error = %v", err)
+	}
+	defer itemStore.Close()
+	frontPage, err := itemStore.FrontPage("hn", fetchedAt)
+	if err != nil || len(frontPage.Items) != 1 || frontPage.Items[0].Title != "Kept story" {
+		t.Errorf("FrontPage() after reopening got = %+v, %v", frontPage, err)
+	}
+}
+
+func TestBoltStore_Prune(t *testing.T) {
+	itemStore := openTestStore(t)
+	morning := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	noon := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	story := data.Item{Id: 1, Title: "Lasting story"}
+	dropped := data.Item{Id: 2, Title: "Dropped story"}
+	if err := itemStore.Record("hn", morning, []data.Item{story, dropped}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := itemStore.Record("hn", noon, []data.Item{story}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	if err := itemStore.Prune(noon); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if frontPage, err := itemStore.FrontPage("hn", morning); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FrontPage() of a pruned fetch got = %+v, %v, want %v", frontPage, err, ErrNotFound)
+	}
+	if frontPage, err := itemStore.FrontPage("hn", noon); err != nil || !frontPage.FetchedAt.Equal(noon) {
+		t.Errorf("FrontPage() of a kept fetch got = %+v, %v", frontPage, err)
+	}
+	history, err := itemStore.History("hn", "1", morning, noon)
+	if err != nil || len(history) != 1 || !history[0].FetchedAt.Equal(noon) {
+		t.Errorf("History() got = %+v, %v, want the kept snapshot only", history, err)
+	}
+	if history, err := itemStore.History("hn", "2", morning, noon); !errors.Is(err, ErrNotFound) {
+		t.Errorf("History() of a pruned item got = %+v, %v, want %v", history, err, ErrNotFound)
+	}
+}
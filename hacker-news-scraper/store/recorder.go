@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+)
+
+// Recorder is a services.Retriever decorator saving in Store the first
+// Listing items of the successful fetches of a source, so that every front
+// page recorded lists as many items. Fetches of fewer items are not
+// recorded. Items are saved in the background, one fetch at a time, fetches
+// made while saving being skipped. Failing to save them is only logged, the
+// fetch still succeeds.
+type Recorder struct {
+	Source    string
+	Retriever services.Retriever
+	Store     Store
+	Listing   int
+	now       func() time.Time
+
+	recording sync.Mutex
+	pending   sync.WaitGroup
+}
+
+func NewRecorder(source string, retriever services.Retriever, store Store, listing int) *Recorder {
+	return &Recorder{Source: source, Retriever: retriever, Store: store, Listing: listing, now: time.Now}
+}
+
+func (r *Recorder) GetItems(ctx context.Context, maxItems int) ([]data.Item, error) {
+	items, err := r.Retriever.GetItems(ctx, maxItems)
+	if err != nil || maxItems < r.Listing {
+		return items, err
+	}
+	if r.recording.TryLock() {
+		r.pending.Add(1)
+		go r.record(context.WithoutCancel(ctx), r.now(), items[:min(r.Listing, len(items))])
+	}
+	return items, nil
+}
+
+func (r *Recorder) record(ctx context.Context, fetchedAt time.Time, items []data.Item) {
+	defer r.pending.Done()
+	defer r.recording.Unlock()
+	if err := r.Store.Record(r.Source, fetchedAt, items); err != nil {
+		slog.WarnContext(ctx, "Failed to record items", "source", r.Source, "error", err)
+	}
+}
+
+// Unwrap returns the decorated retriever.
+func (r *Recorder) Unwrap() services.Retriever {
+	return r.Retriever
+}
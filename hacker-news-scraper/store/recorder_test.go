@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	mock_services "github.com/IntelligenzCodeLab/hacker-news-scraper/services/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestRecorder_GetItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	items := []data.Item{{Id: 1, Title: "First story", Score: 10}, {Id: 2, Title: "Second story", Score: 5}, {Id: 3, Title: "Third story", Score: 1}}
+	fetchedAt := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		maxItems   int
+		err        error
+		wantRecord []string
+	}{
+		{name: "Successful fetch is recorded", maxItems: 2, wantRecord: []string{"1", "2"}},
+		{name: "Larger fetch records the listing size", maxItems: 3, wantRecord: []string{"1", "2"}},
+		{name: "Smaller fetch is not recorded", maxItems: 1},
+		{name: "Failed fetch is not recorded", maxItems: 2, err: errors.New("unavailable")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			itemStore := openTestStore(t)
+			fetcher := mock_services.NewMockRetriever(ctrl)
+			fetcher.EXPECT().GetItems(gomock.Any(), tt.maxItems).Return(items[:tt.maxItems], tt.err)
+			recorder := NewRecorder("hn", fetcher, itemStore, 2)
+			recorder.now = func() time.Time { return fetchedAt }
+
+			if _, err := recorder.GetItems(context.Background(), tt.maxItems); !errors.Is(err, tt.err) {
+				t.Fatalf("GetItems() error = %v, want %v", err, tt.err)
+			}
+			recorder.pending.Wait()
+			page, err := itemStore.FrontPage("hn", fetchedAt)
+			if recorded := err == nil; recorded != (tt.wantRecord != nil) {
+				t.Fatalf("FrontPage() error = %v, want recorded %v", err, tt.wantRecord != nil)
+			}
+			var gotRecord []string
+			for _, snapshot := range page.Items {
+				gotRecord = append(gotRecord, snapshot.Id)
+			}
+			if !slices.Equal(gotRecord, tt.wantRecord) {
+				t.Errorf("FrontPage() got items %v, want %v", gotRecord, tt.wantRecord)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+)
+
+var ErrNotFound = errors.New("no snapshots found")
+
+// Store keeps the snapshots of the items returned by every source fetch.
+type Store interface {
+	// Record saves the items fetched from source at fetchedAt, in source order.
+	Record(source string, fetchedAt time.Time, items []data.Item) error
+	// History returns the snapshots of an item of source taken between from
+	// and to, oldest first.
+	History(source string, itemId string, from time.Time, to time.Time) ([]data.ItemSnapshot, error)
+	// FrontPage returns the last fetch of source taken at or before at.
+	FrontPage(source string, at time.Time) (data.FrontPage, error)
+	// Prune removes the front pages and item snapshots fetched before before.
+	Prune(before time.Time) error
+	Close() error
+}
+
+// Retain prunes from store the fetches older than retention every interval
+// until ctx is done, the store otherwise growing with every fetch.
+func Retain(ctx context.Context, store Store, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := store.Prune(time.Now().Add(-retention)); err != nil {
+			slog.WarnContext(ctx, "Failed to prune item store", "retention", retention, "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// snapshots ranks items by their position, retrievers returning the items
+// in source order.
+func snapshots(fetchedAt time.Time, items []data.Item) []data.ItemSnapshot {
+	snapshots := make([]data.ItemSnapshot, len(items))
+	for i, item := range items {
+		snapshots[i] = data.ItemSnapshot{Id: services.ItemId(item), FetchedAt: fetchedAt, Rank: i + 1, Title: item.Title, Url: item.Url, Score: item.Score, Comments: item.Descendants}
+	}
+	return snapshots
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+)
+
+const streamInterval = 10 * time.Second
+
+// BuildItemsStreamHandler streams as Server-Sent Events the changes of the
+// ranking of the sources selected as in BuildItemsRetrieverHandler. The
+// ranking is sent when the stream starts, then aggregated again every
+// interval and diffed with the previous one, sending an event per change.
+func BuildItemsStreamHandler(registry *services.SourceRegistry, defaults itemsDefaults, interval time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseItemsQuery(r, registry, defaults)
+		if err != nil {
+			writeParameterError(w, err)
+			return
+		}
+		thresholds, err := parseRankingThresholds(r)
+		if err != nil {
+			writeParameterError(w, err)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		stream := &eventStream{w: w}
+		fmt.Fprintf(w, "retry: %d\n\n", interval.Milliseconds())
+
+		aggregator := services.Aggregator{Connectors: query.connectors, PartialResults: true, Ranker: query.ranker, Deduplicate: true, Filter: query.filter}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var previous []data.Item
+		started := false
+		for {
+			items, _, err := aggregator.GetItemsWithStatus(r.Context(), maxItemsWindow)
+			if r.Context().Err() != nil {
+				return
+			}
+			switch {
+			case err != nil:
+				slog.ErrorContext(r.Context(), "Failed to get items", "error", err)
+				stream.send("error", data.ErrorResponse{Error: data.ErrorDetail{Code: "sources_unavailable", Message: "Error obtaining required data"}})
+			case !started:
+				started = true
+				previous = items[min(query.offset, len(items)):min(query.offset+query.limit, len(items))]
+				ranking := make([]data.ScraperResponse, len(previous))
+				for i, item := range previous {
+					ranking[i] = scraperResponse(item, query.offset+i+1)
+				}
+				stream.send(string(data.RankingSnapshot), ranking)
+			default:
+				current := items[min(query.offset, len(items)):min(query.offset+query.limit, len(items))]
+				for _, event := range services.DiffRankings(previous, current, thresholds) {
+					event.Rank, event.PreviousRank = offsetRank(event.Rank, query.offset), offsetRank(event.PreviousRank, query.offset)
+					stream.send(string(event.Type), event)
+				}
+				previous = current
+			}
+			if stream.sent == 0 {
+				// Comments keep idle connections open through proxies.
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if stream.err != nil {
+				slog.InfoContext(r.Context(), "Stream closed", "error", stream.err)
+				return
+			}
+			flusher.Flush()
+			stream.sent = 0
+
+			select {
+			case <-ticker.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// eventStream writes Server-Sent Events, numbering them in sending order.
+type eventStream struct {
+	w    http.ResponseWriter
+	id   int
+	sent int
+	err  error
+}
+
+func (s *eventStream) send(event string, payload any) {
+	if s.err != nil {
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.id++
+	s.sent++
+	_, s.err = fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", s.id, event, body)
+}
+
+// offsetRank shifts a rank of the streamed page to the overall ranking.
+func offsetRank(rank int, offset int) int {
+	if rank == 0 {
+		return 0
+	}
+	return rank + offset
+}
+
+// parseRankingThresholds reads the score_thresholds and comment_thresholds
+// parameters, comma separated numbers, using the default thresholds when
+// absent.
+func parseRankingThresholds(r *http.Request) (services.RankingThresholds, error) {
+	thresholds := services.DefaultRankingThresholds
+	parameters := []struct {
+		name   string
+		target *[]int
+	}{
+		{name: "score_thresholds", target: &thresholds.Score},
+		{name: "comment_thresholds", target: &thresholds.Comments},
+	}
+	for _, parameter := range parameters {
+		value := r.URL.Query().Get(parameter.name)
+		if value == "" {
+			continue
+		}
+		numbers, err := parseNumbers(value)
+		if err != nil {
+			return thresholds, &parameterError{code: "invalid_parameter", parameter: parameter.name, message: parameter.name + " must be comma separated positive numbers"}
+		}
+		*parameter.target = numbers
+	}
+	return thresholds, nil
+}
+
+func parseNumbers(value string) ([]int, error) {
+	numbers := make([]int, 0)
+	for _, field := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || number <= 0 {
+			return nil, fmt.Errorf("invalid number %q", field)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
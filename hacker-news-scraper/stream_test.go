@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+)
+
+// rankingsFetcher returns its rankings one after the other, repeating the
+// last one, and signals when every ranking was fetched.
+type rankingsFetcher struct {
+	mu       sync.Mutex
+	rankings [][]data.Item
+	fetches  int
+	done     chan struct{}
+}
+
+func (f *rankingsFetcher) GetItems(_ context.Context, maxItems int) ([]data.Item, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ranking := f.rankings[min(f.fetches, len(f.rankings)-1)]
+	if f.fetches++; f.fetches == len(f.rankings)+1 {
+		close(f.done)
+	}
+	return ranking[:min(maxItems, len(ranking))], nil
+}
+
+type streamEvent struct {
+	name string
+	data string
+}
+
+func readEvents(body string) []streamEvent {
+	events := make([]streamEvent, 0)
+	var event streamEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event.name != "":
+			events = append(events, event)
+			event = streamEvent{}
+		}
+	}
+	return events
+}
+
+func TestStreamItems(t *testing.T) {
+	first := data.Item{Id: 1, Title: "First story", Score: 90}
+	second := data.Item{Id: 2, Title: "Second story", Score: 80}
+	third := data.Item{Id: 3, Title: "Third story", Score: 70}
+	climbing := second
+	climbing.Score = 120
+	fetcher := &rankingsFetcher{rankings: [][]data.Item{{first, second}, {first, second}, {climbing, first}, {climbing, third}}, done: make(chan struct{})}
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": fetcher})
+	handler := BuildItemsStreamHandler(registry, itemsDefaults{sources: []string{"hn"}}, 5*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/items/stream?sort=score&limit=2&score_thresholds=100", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	go func() {
+		<-fetcher.done
+		cancel()
+	}()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type header = %q, want %q", got, "text/event-stream")
+	}
+	events := readEvents(rr.Body.String())
+	wantNames := []string{"ranking", "moved", "score_threshold", "moved", "entered", "left"}
+	gotNames := make([]string, len(events))
+	for i, event := range events {
+		gotNames[i] = event.name
+	}
+	if strings.Join(gotNames, ",") != strings.Join(wantNames, ",") {
+		t.Fatalf("stream sent events %v, want %v", gotNames, wantNames)
+	}
+	var ranking []data.ScraperResponse
+	if err := json.Unmarshal([]byte(events[0].data), &ranking); err != nil || len(ranking) != 2 || ranking[0].Id != "1" {
+		t.Errorf("ranking event = %s, want the initial ranking", events[0].data)
+	}
+	var crossing data.RankingEvent
+	if err := json.Unmarshal([]byte(events[2].data), &crossing); err != nil || crossing.Id != "2" || crossing.Threshold != 100 || crossing.Rank != 1 {
+		t.Errorf("score_threshold event = %s, want item 2 crossing 100", events[2].data)
+	}
+	if !strings.Contains(rr.Body.String(), ": keep-alive") {
+		t.Errorf("stream sent no keep-alive for the unchanged ranking")
+	}
+}
+
+func TestStreamItemsParameters(t *testing.T) {
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": &rankingsFetcher{}})
+	handler := BuildItemsStreamHandler(registry, itemsDefaults{sources: []string{"hn"}}, time.Second)
+	for _, target := range []string{"/items/stream?score_thresholds=high", "/items/stream?comment_thresholds=10,-1", "/items/stream?sources=reddit", "/items/stream?offset=9223372036854775807"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s returned status %v, want %v", target, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	"github.com/gorilla/websocket"
+)
+
+const heartbeatInterval = 30 * time.Second
+const subscriptionWriteTimeout = 10 * time.Second
+const subscriptionBuffer = 16
+
+// subscriptionReadLimit bounds the size of the requests of clients, far
+// above the size of their filters.
+const subscriptionReadLimit = 64 << 10
+
+var upgrader = websocket.Upgrader{}
+
+// subscriptionsUnavailable answers subscriptions when the sources are
+// neither polled nor cached.
+func subscriptionsUnavailable(w http.ResponseWriter, r *http.Request) {
+	writeJSONError(w, http.StatusServiceUnavailable, data.ErrorDetail{Code: "subscriptions_unavailable", Message: "Subscriptions need polled or cached sources"})
+}
+
+// BuildSubscriptionsHandler serves a WebSocket where clients subscribe with
+// filters, replaceable at any time, to the new items discovered by hub in
+// the registry sources. Heartbeats are sent every heartbeat. Subscriptions
+// are closed once the request context is done.
+func BuildSubscriptionsHandler(registry *services.SourceRegistry, hub *services.ItemsHub, heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Failed to open subscription", "error", err)
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(subscriptionReadLimit)
+		conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+		})
+
+		// Requests are read aside, every write happens in the loop below.
+		requests := make(chan []byte)
+		readErrors := make(chan error, 1)
+		go func() {
+			for {
+				_, request, err := conn.ReadMessage()
+				if err != nil {
+					readErrors <- err
+					return
+				}
+				conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+				select {
+				case requests <- request:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}()
+
+		hubSubscription := hub.Subscribe(subscriptionBuffer)
+		defer hubSubscription.Close()
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		var subscription *itemSubscription
+		for {
+			var messages []data.SubscriptionMessage
+			select {
+			case request := <-requests:
+				messages = []data.SubscriptionMessage{handleSubscriptionRequest(registry, request, &subscription)}
+				if subscription != nil {
+					hubSubscription.Follow(subscription.filters.Sources)
+				}
+			case discovered := <-hubSubscription.Items:
+				if subscription != nil {
+					messages = subscription.matching(discovered)
+				}
+			case now := <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, now.Add(subscriptionWriteTimeout)); err != nil {
+					return
+				}
+				messages = []data.SubscriptionMessage{{Type: "heartbeat", Time: &now}}
+			case <-r.Context().Done():
+				// The request is ended, such as when the app is replaced, clients
+				// subscribing again.
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(subscriptionWriteTimeout))
+				return
+			case err := <-readErrors:
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					slog.InfoContext(r.Context(), "Subscription closed", "error", err)
+				}
+				return
+			}
+			for _, message := range messages {
+				conn.SetWriteDeadline(time.Now().Add(subscriptionWriteTimeout))
+				if err := conn.WriteJSON(message); err != nil {
+					slog.InfoContext(r.Context(), "Subscription closed", "error", err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// itemSubscription holds the filters of a subscription client.
+type itemSubscription struct {
+	filters data.SubscriptionFilters
+	filter  services.ItemFilter
+}
+
+func (s *itemSubscription) matching(discovered []services.DiscoveredItem) []data.SubscriptionMessage {
+	messages := make([]data.SubscriptionMessage, 0)
+	for _, discovery := range discovered {
+		if len(s.filters.Sources) > 0 && !slices.Contains(s.filters.Sources, discovery.Source) {
+			continue
+		}
+		if s.filter.Matches(discovery.Item) {
+			item := scraperResponse(discovery.Item, 0)
+			messages = append(messages, data.SubscriptionMessage{Type: "item", Source: discovery.Source, Item: &item})
+		}
+	}
+	return messages
+}
+
+// handleSubscriptionRequest applies the request message to subscription,
+// returning the message answering it.
+func handleSubscriptionRequest(registry *services.SourceRegistry, message []byte, subscription **itemSubscription) data.SubscriptionMessage {
+	var request data.SubscriptionRequest
+	if err := json.Unmarshal(message, &request); err != nil {
+		return subscriptionError("invalid_request", "", "malformed request: "+err.Error())
+	}
+	if request.Type != "subscribe" {
+		return subscriptionError("invalid_request", "", fmt.Sprintf("unknown request type %q, expected subscribe", request.Type))
+	}
+	filters := request.Filters
+	for _, key := range filters.Sources {
+		if _, ok := registry.Get(key); !ok {
+			message := fmt.Sprintf("unknown source %q, available sources: %s", key, strings.Join(registry.Keys(), ", "))
+			return subscriptionError("unknown_source", "sources", message)
+		}
+	}
+	if filters.MinScore < 0 {
+		return subscriptionError("invalid_parameter", "min_score", "min_score must be a non negative number")
+	}
+	*subscription = &itemSubscription{filters: filters, filter: services.ItemFilter{Keywords: filters.Keywords, MinScore: filters.MinScore, Domains: filters.Domains}}
+	return data.SubscriptionMessage{Type: "subscribed", Filters: &filters}
+}
+
+func subscriptionError(code string, parameter string, message string) data.SubscriptionMessage {
+	return data.SubscriptionMessage{Type: "error", Error: &data.ErrorDetail{Code: code, Parameter: parameter, Message: message}}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/data"
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	"github.com/gorilla/websocket"
+)
+
+// listingFetcher returns the items last set, signalling its first fetch.
+type listingFetcher struct {
+	mu      sync.Mutex
+	items   []data.Item
+	fetched chan struct{}
+	once    sync.Once
+}
+
+func (f *listingFetcher) GetItems(_ context.Context, maxItems int) ([]data.Item, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.once.Do(func() { close(f.fetched) })
+	return f.items[:min(maxItems, len(f.items))], nil
+}
+
+func (f *listingFetcher) set(items ...data.Item) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items = items
+}
+
+// readMessage returns the next subscription message other than heartbeats.
+func readMessage(t *testing.T, conn *websocket.Conn) data.SubscriptionMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var message data.SubscriptionMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("could not read message: %v", err)
+		}
+		if message.Type != "heartbeat" {
+			return message
+		}
+	}
+}
+
+func TestSubscribeItems(t *testing.T) {
+	listed := data.Item{Id: 1, Title: "Listed before subscribing", Url: "https://go.dev/blog", Score: 300}
+	matching := data.Item{Id: 2, Title: "Go 1.23 is released", Url: "https://go.dev/blog/go1.23", Score: 150}
+	lowScore := data.Item{Id: 3, Title: "A Go tutorial", Url: "https://go.dev/tour", Score: 10}
+	otherDomain := data.Item{Id: 4, Title: "Go generics explained", Url: "https://example.com/generics", Score: 200}
+	lobstersItem := data.Item{Id: 5, Title: "Go on Lobsters", Url: "https://go.dev/lobsters", Score: 80}
+
+	hnFetcher := &listingFetcher{items: []data.Item{listed}, fetched: make(chan struct{})}
+	lobstersFetcher := &listingFetcher{items: []data.Item{}, fetched: make(chan struct{})}
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": hnFetcher, "lobsters": lobstersFetcher})
+	hub := services.NewItemsHub(registry, 5*time.Millisecond, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	server := httptest.NewServer(BuildSubscriptionsHandler(registry, hub, 20*time.Millisecond))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("could not open subscription: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(data.SubscriptionRequest{Type: "subscribe", Filters: data.SubscriptionFilters{Sources: []string{"hn"}, Keywords: []string{"go"}, MinScore: 50, Domains: []string{"go.dev"}}})
+	if message := readMessage(t, conn); message.Type != "subscribed" || message.Filters == nil || message.Filters.MinScore != 50 {
+		t.Fatalf("subscribe answered with %+v, want subscribed", message)
+	}
+	<-hnFetcher.fetched
+	lobstersFetcher.set(lobstersItem)
+	hnFetcher.set(listed, lowScore, otherDomain, matching)
+	message := readMessage(t, conn)
+	if message.Type != "item" || message.Source != "hn" || message.Item == nil || message.Item.Id != "2" {
+		t.Fatalf("received %+v, want item 2 of hn", message)
+	}
+
+	requests := []struct {
+		name      string
+		request   string
+		wantCode  string
+		wantParam string
+	}{
+		{name: "Malformed request", request: `{"type":`, wantCode: "invalid_request"},
+		{name: "Unknown request type", request: `{"type":"unsubscribe"}`, wantCode: "invalid_request"},
+		{name: "Unknown source", request: `{"type":"subscribe","filters":{"sources":["reddit"]}}`, wantCode: "unknown_source", wantParam: "sources"},
+		{name: "Negative score", request: `{"type":"subscribe","filters":{"min_score":-1}}`, wantCode: "invalid_parameter", wantParam: "min_score"},
+	}
+	for _, tt := range requests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn.WriteMessage(websocket.TextMessage, []byte(tt.request))
+			message := readMessage(t, conn)
+			if message.Type != "error" || message.Error == nil || message.Error.Code != tt.wantCode || message.Error.Parameter != tt.wantParam {
+				t.Errorf("request answered with %+v, want error %s on %q", message, tt.wantCode, tt.wantParam)
+			}
+		})
+	}
+
+	select {
+	case <-lobstersFetcher.fetched:
+		t.Fatalf("fetched lobsters, which no subscription follows")
+	default:
+	}
+
+	// Filters are replaced by later subscriptions.
+	conn.WriteJSON(data.SubscriptionRequest{Type: "subscribe", Filters: data.SubscriptionFilters{Sources: []string{"lobsters"}}})
+	if message := readMessage(t, conn); message.Type != "subscribed" {
+		t.Fatalf("subscribe answered with %+v, want subscribed", message)
+	}
+	<-lobstersFetcher.fetched
+	next := data.Item{Id: 6, Title: "Rust on Lobsters", Url: "https://example.com/rust", Score: 1}
+	hnFetcher.set(listed, data.Item{Id: 7, Title: "Not followed", Score: 500})
+	lobstersFetcher.set(lobstersItem, next)
+	message = readMessage(t, conn)
+	if message.Type != "item" || message.Source != "lobsters" || message.Item == nil || message.Item.Id != "6" {
+		t.Fatalf("received %+v, want item 6 of lobsters", message)
+	}
+}
+
+func TestSubscribeItemsReadLimit(t *testing.T) {
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": &listingFetcher{items: []data.Item{}, fetched: make(chan struct{})}})
+	hub := services.NewItemsHub(registry, time.Hour, 10)
+	server := httptest.NewServer(BuildSubscriptionsHandler(registry, hub, time.Hour))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("could not open subscription: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe","filters":{"keywords":["`+strings.Repeat("go", subscriptionReadLimit)+`"]}}`))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("oversized request answered with %v, want the subscription closed", err)
+	}
+}
+
+func TestSubscribeItemsHeartbeat(t *testing.T) {
+	registry := newTestRegistry(t, map[string]services.Retriever{"hn": &listingFetcher{items: []data.Item{}, fetched: make(chan struct{})}})
+	hub := services.NewItemsHub(registry, time.Hour, 10)
+	server := httptest.NewServer(BuildSubscriptionsHandler(registry, hub, 10*time.Millisecond))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("could not open subscription: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message data.SubscriptionMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	if message.Type != "heartbeat" || message.Time == nil {
+		t.Errorf("received %+v, want a heartbeat", message)
+	}
+}
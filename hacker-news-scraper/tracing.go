@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const serviceName = "hacker-news-scraper"
+
+// setupTracing installs the tracer provider of the spans exported as
+// exporter states, see newTracerProvider. The W3C trace context of requests
+// is propagated in every case. The returned function flushes the pending
+// spans.
+func setupTracing(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	provider, err := newTracerProvider(ctx, exporter)
+	if err != nil || provider == nil {
+		return func(context.Context) error { return nil }, err
+	}
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// newTracerProvider builds the tracer provider exporting the spans as
+// exporter states: "otlp" sends them to the OTLP/HTTP endpoint set through
+// the OTEL_EXPORTER_OTLP_* variables, "stdout" prints them and "" or "none"
+// leaves them unexported, returning no provider.
+func newTracerProvider(ctx context.Context, exporter string) (*sdktrace.TracerProvider, error) {
+	var option sdktrace.TracerProviderOption
+	switch exporter {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		otlpExporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		option = sdktrace.WithBatcher(otlpExporter)
+	case "stdout":
+		stdoutExporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, err
+		}
+		option = sdktrace.WithSyncer(stdoutExporter)
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q, expected otlp, stdout or none", exporter)
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(option, sdktrace.WithResource(serviceResource)), nil
+}
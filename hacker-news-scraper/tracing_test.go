@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IntelligenzCodeLab/hacker-news-scraper/services"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	tests := []struct {
+		exporter     string
+		wantProvider bool
+		wantErr      bool
+	}{
+		{exporter: "", wantProvider: false},
+		{exporter: "none", wantProvider: false},
+		{exporter: "stdout", wantProvider: true},
+		{exporter: "otlp", wantProvider: true},
+		{exporter: "jaeger", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.exporter, func(t *testing.T) {
+			provider, err := newTracerProvider(context.Background(), tt.exporter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newTracerProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (provider != nil) != tt.wantProvider {
+				t.Errorf("newTracerProvider() provider = %v, want provider %v", provider, tt.wantProvider)
+			}
+			if provider != nil {
+				provider.Shutdown(context.Background())
+			}
+		})
+	}
+}
+
+func TestTraceItemsRequest(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var upstreamTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hottest.json" {
+			w.Write([]byte("[]"))
+			return
+		}
+		upstreamTraceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`[{"short_id":"one3oq","created_at":"2024-06-08T09:04:23.000-05:00","title":"Stupid Slow","url":"https://www.datagubbe.se/stupidslow/","score":21}]`))
+	}))
+	defer upstream.Close()
+	connector := &services.LobstersAPIConnector{Url: upstream.URL, EndPoint: services.LobstersHottest, Client: services.NewHTTPClient(services.DefaultHTTPClientConfig)}
+	registry := newTestRegistry(t, map[string]services.Retriever{"lobsters": services.NewInstrumentedRetriever("lobsters", connector)})
+	r := mux.NewRouter()
+	r.HandleFunc("/items", BuildItemsRetrieverHandler(registry, itemsDefaults{sources: []string{"lobsters"}})).Methods("GET")
+	if err := instrumentRoutes(r); err != nil {
+		t.Fatalf("could not instrument routes: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	ended := spans.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan, len(ended))
+	for _, span := range ended {
+		if span.SpanKind() == trace.SpanKindClient {
+			if _, ok := byName["client"]; !ok {
+				byName["client"] = span
+			}
+			continue
+		}
+		byName[span.Name()] = span
+	}
+	parents := []struct {
+		span   string
+		parent string
+	}{
+		{span: "Aggregator.GetItems", parent: "GET /items"},
+		{span: "Aggregator.fetchSource", parent: "Aggregator.GetItems"},
+		{span: "Retriever.GetItems", parent: "Aggregator.fetchSource"},
+		{span: "client", parent: "Retriever.GetItems"},
+	}
+	for _, pair := range parents {
+		span, parent := byName[pair.span], byName[pair.parent]
+		if span == nil || parent == nil {
+			t.Fatalf("spans %q and %q were not recorded, got %d spans", pair.span, pair.parent, len(ended))
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %q is not a child of %q", pair.span, pair.parent)
+		}
+	}
+
+	server := byName["GET /items"]
+	if got := server.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span trace id = %s, want the trace id of the request", got)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent id = %s, want the span id of the request", got)
+	}
+	wantTraceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + byName["client"].SpanContext().SpanID().String() + "-01"
+	if upstreamTraceparent != wantTraceparent {
+		t.Errorf("upstream request traceparent = %q, want %q", upstreamTraceparent, wantTraceparent)
+	}
+}